@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"nondv.io/glisp/interpreter"
+	"nondv.io/glisp/reader"
+	. "nondv.io/glisp/types"
+	"nondv.io/glisp/vm"
+)
+
+// Recursive functions from lang/core.lisp, run by each evaluator
+var coreBenchmarks = []struct{ name, code string }{
+	{"reduce", "(reduce 0 + numbers)"},
+	{"mapcar", "(mapcar (lambda (x) (+ x 1)) numbers)"},
+}
+
+func BenchmarkCore(b *testing.B) {
+	bindings := interpreter.BuildBaseBindings()
+	if _, err := interpreter.ReadEval(bindings, `(load "lang/core.lisp")`); err != nil {
+		b.Fatal(err)
+	}
+
+	numbers := make([]string, 100)
+	for i := range numbers {
+		numbers[i] = fmt.Sprint(i)
+	}
+	if _, err := interpreter.ReadEval(bindings, fmt.Sprintf("(define numbers (list %s))", strings.Join(numbers, " "))); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, benchmark := range coreBenchmarks {
+		form, err := reader.Read(benchmark.code)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		compiled := interpreter.Compile(form)
+		bytecode := vm.Compile(form)
+		runners := []struct {
+			name string
+			run  func() (*Value, error)
+		}{
+			{"eval", func() (*Value, error) { return interpreter.Eval(bindings, form) }},
+			{"compile", func() (*Value, error) { return compiled(bindings) }},
+			{"vm", func() (*Value, error) { return vm.Run(bindings, bytecode) }},
+		}
+
+		for _, runner := range runners {
+			run := runner.run
+			b.Run(benchmark.name+"/"+runner.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := run(); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
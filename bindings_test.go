@@ -11,21 +11,78 @@ import (
 func TestBase(t *testing.T) {
 	bindings := interpreter.BuildBaseBindings()
 
-	val, err := interpreter.ReadEval(bindings, "nil")
+	val, err := readEval(bindings, "nil")
 	require.NoError(t, err)
 	require.True(t, val.IsEmptyList())
 
-	// val, err = ReadEval(bindings, "t")
-	// require.NoError(t, err)
-	// require.True(t, val.IsSymbol())
-	// require.Equal(t, "t", *val.Value.(*string))
+	val, err = readEval(bindings, "t")
+	require.NoError(t, err)
+	require.True(t, val.IsBool())
+	require.True(t, val.ToBool())
+
+	val, err = readEval(bindings, "false")
+	require.NoError(t, err)
+	require.Equal(t, BuildBool(false), val)
 
-	val, err = interpreter.ReadEval(bindings, "a")
+	val, err = readEval(bindings, "a")
 	require.NotNil(t, err)
 
 	bindings = bindings.Assoc(BuildSymbol("a"), BuildInteger(123))
-	val, err = interpreter.ReadEval(bindings, "a")
+	val, err = readEval(bindings, "a")
 	require.NoError(t, err)
 	require.True(t, val.IsInteger())
 	require.Equal(t, 123, val.Value.(int))
 }
+
+func TestDefine(t *testing.T) {
+	base := interpreter.BuildBaseBindings()
+
+	// bindings derived before the definition see it (e.g. per-request
+	// bindings in a web server during hot reload)
+	derived := base.AssocSym("request-data", BuildInteger(1))
+	_, err := readEval(base, "(define handler 1)")
+	require.NoError(t, err)
+	require.Equal(t, "1", readEvalPrintNoErr(derived, "handler"))
+
+	// redefinition
+	_, err = readEval(derived, "(define handler 2)")
+	require.NoError(t, err)
+	require.Equal(t, "2", readEvalPrintNoErr(base, "handler"))
+
+	// define is always global, even inside let
+	_, err = readEval(base, "(let ((x 3)) (define from-let x))")
+	require.NoError(t, err)
+	require.Equal(t, "3", readEvalPrintNoErr(base, "from-let"))
+
+	// local bindings still shadow globals
+	require.Equal(t, "10", readEvalPrintNoErr(base, "(let ((handler 10)) handler)"))
+}
+
+func TestSet(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+
+	_, err := readEval(bindings, "(define counter 0)")
+	require.NoError(t, err)
+	_, err = readEval(bindings, "(set! counter (+ counter 1))")
+	require.NoError(t, err)
+	require.Equal(t, "1", readEvalPrintNoErr(bindings, "counter"))
+
+	// nearest binding is changed, global stays the same
+	code := "(let ((counter 10)) (set! counter 20) counter)"
+	require.Equal(t, "20", readEvalPrintNoErr(bindings, code))
+	require.Equal(t, "1", readEvalPrintNoErr(bindings, "counter"))
+
+	// global is changed from inside a function
+	code = "((lambda (x) (set! counter x)) 5)"
+	require.Equal(t, "5", readEvalPrintNoErr(bindings, code))
+	require.Equal(t, "5", readEvalPrintNoErr(bindings, "counter"))
+
+	// lambda parameter
+	code = "((lambda (x y) (set! x (+ x y)) x) 1 2)"
+	require.Equal(t, "3", readEvalPrintNoErr(bindings, code))
+
+	_, err = readEval(bindings, "(set! undefined-thing 1)")
+	require.ErrorContains(t, err, "undefined-thing")
+	_, err = readEval(bindings, "undefined-thing")
+	require.NotNil(t, err)
+}
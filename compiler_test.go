@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"nondv.io/glisp/interpreter"
+	"nondv.io/glisp/reader"
+	. "nondv.io/glisp/types"
+)
+
+// Compiled code must behave exactly like Eval
+var compilerCases = []string{
+	"nil",
+	"(lambda X 123)",
+	"(+ 1 2 3)",
+	"(= 1 1)",
+	"((lambda X (car X)) bla)",
+	"((lambda X (+ (car (cdr X)) (car X))) 123 456)",
+	"((lambda quote (quote a b c d)) lambda X X)",
+	`(let ((double (lambda X (+ (car X) (car X))))) (double 123))`,
+	`((lambda X (eval (car X))) (+ 123 111))`,
+	`(let ((double (lambda X
+                         (let ((x (eval (car X))))
+                           (+ x x))))
+               (quadriple (lambda X
+                            (let ((x (eval (car X))))
+                              (double (double x))))))
+           (quadriple (+ 1 2 3)))`,
+	"((lambda (x) (+ x x)) (+ 1 2 3))",
+	"((lambda (x y) (let ((z (+ x y)) (w (+ z z))) (cons z w))) 1 2)",
+	"(let ((value nil)) (if value 1 2))",
+	"(let ((x 1)) (let ((x 2) (y x)) y))",
+	"(cons 1 (cons 2 ()))",
+	`{"a" (+ 1 2)}`,
+	"(let ((x 1)) [x (+ x 1)])",
+	"(->> 100 (+ 20) (+ 3) (list) (push-last 456))",
+	"(reduce 0 + (list 1 2 3 4))",
+	"(mapcar (lambda (x) (+ x 1)) (list 1 2 3))",
+	`(cond ((= 1 2) "a") ((= 1 1) "b"))`,
+	// rebinding special forms
+	"(let ((if (lambda (a b c) c))) (if 1 2 3))",
+	"((lambda (let) (let 1)) (lambda (x) x))",
+	// dynamic binding
+	"(let ((f (lambda () x)) (x 5)) (f))",
+	"((lambda (x) ((lambda () x))) 7)",
+	// set! on locals
+	"((lambda (x) (set! x 10) x) 1)",
+	"(let ((x 1)) (set! x 2) x)",
+	// values aren't evaluated again
+	"(apply (lambda (x y) (cons x y)) (list (list 1 2) 3))",
+	"((compose (partial + 1) (lambda (x) (+ x x))) 5)",
+}
+
+func TestCompiler(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	_, err := interpreter.ReadEval(bindings, `(load "lang/core.lisp")`)
+	require.NoError(t, err)
+
+	for _, code := range compilerCases {
+		expected := readEvalPrintNoErr(bindings, code)
+		require.Equal(t, expected, compileRunPrintNoErr(bindings, code), code)
+	}
+}
+
+func TestCompilerErrors(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+
+	for _, code := range []string{"undefined", "(if 1 2)", "((lambda (x) x))", "((lambda (x) x) 1 2)", "(1 2)", "(+ 1 . 2)", "(car . 1)", "(car (+ 1 . 2))"} {
+		_, evalErr := interpreter.ReadEval(bindings, code)
+		require.NotNil(t, evalErr, code)
+
+		form, err := reader.Read(code)
+		require.NoError(t, err)
+		_, compiledErr := interpreter.Compile(form)(bindings)
+		require.Equal(t, evalErr, compiledErr, code)
+	}
+}
+
+func TestCompiledRedefinition(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	form, _ := reader.Read("(handler 1)")
+	code := interpreter.Compile(form)
+
+	interpreter.ReadEval(bindings, "(define handler (lambda (x) (+ x 1)))")
+	result, err := code(bindings)
+	require.NoError(t, err)
+	require.Equal(t, "2", result.PrintStr())
+
+	interpreter.ReadEval(bindings, "(define handler (lambda (x) (+ x 100)))")
+	result, err = code(bindings)
+	require.NoError(t, err)
+	require.Equal(t, "101", result.PrintStr())
+}
+
+// Compiled calls count against the same limits as Eval
+func TestCompiledLimits(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	interpreter.ReadEval(bindings, "(define loop (lambda (n) (loop (+ n 1))))")
+	interpreter.ReadEval(bindings, "(define deep (lambda (n) (if (= n 0) 0 (+ 1 (deep (+ n -1))))))")
+	interpreter.ReadEval(bindings, "(define spin (lambda (n) (if (= n 0) 0 (spin (+ n -1)))))")
+
+	run := func(rt *Runtime, code string) error {
+		form, err := reader.Read(code)
+		require.NoError(t, err)
+		_, err = interpreter.Compile(form)(bindings.WithRuntime(rt))
+		return err
+	}
+
+	require.Equal(t, &LimitExceededError{Limit: "max depth"}, run(&Runtime{MaxDepth: 100}, "(deep 1000)"))
+	require.Equal(t, &LimitExceededError{Limit: "max steps"}, run(&Runtime{MaxSteps: 1000, MaxDepth: 100000}, "(spin 100000)"))
+	require.NoError(t, run(&Runtime{MaxDepth: 1000}, "(deep 10)"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, run(&Runtime{Context: ctx}, "(loop 0)"), context.Canceled)
+}
+
+func compileRunPrintNoErr(bindings *Bindings, txt string) string {
+	form, err := reader.Read(txt)
+	if err != nil {
+		panic(err)
+	}
+
+	res, err := interpreter.Compile(form)(bindings)
+	if err != nil {
+		println(err.Error())
+		panic("err!")
+	}
+	return res.PrintStr()
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"nondv.io/glisp/interpreter"
+	"nondv.io/glisp/reader"
+	. "nondv.io/glisp/types"
+	"nondv.io/glisp/vm"
+)
+
+// These are meant to be run with -race: go test -race ./...
+
+func TestConcurrentEval(t *testing.T) {
+	ctx := context.Background()
+	in := interpreter.New()
+	_, err := in.Eval(ctx, `(load "lang/core.lisp") (define numbers (list 1 2 3 4 5))`)
+	require.NoError(t, err)
+
+	form, err := reader.Read("(reduce 0 + (mapcar (lambda (x) (+ x 1)) numbers))")
+	require.NoError(t, err)
+
+	// every evaluator, including the ones caching compiled lambdas
+	runners := []func() (*Value, error){
+		func() (*Value, error) { return interpreter.Eval(in.Bindings(), form) },
+		func() (*Value, error) { return interpreter.Compile(form)(in.Bindings()) },
+		func() (*Value, error) { return vm.Eval(in.Bindings(), form) },
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				result, err := runners[(i+j)%len(runners)]()
+				if err != nil || result.PrintStr() != "20" {
+					t.Errorf("unexpected result %v %v", result, err)
+					return
+				}
+
+				// globals can be (re)defined while others are evaluating
+				if _, err := in.Eval(ctx, fmt.Sprintf("(define tmp-%d %d)", i, j)); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < 8; i++ {
+		result, err := in.Eval(ctx, fmt.Sprintf("tmp-%d", i))
+		require.NoError(t, err)
+		require.Equal(t, "19", result.PrintStr())
+	}
+}
+
+func TestConcurrentReload(t *testing.T) {
+	ctx := context.Background()
+	in := interpreter.New()
+	_, err := in.Eval(ctx, `(define version 0) (define get-version (lambda () version))`)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if _, err := in.Call(ctx, "get-version"); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+
+	for version := 1; version <= 20; version++ {
+		next := in.Fork()
+		_, err := next.Eval(ctx, fmt.Sprintf("(define version %d)", version))
+		require.NoError(t, err)
+
+		// nothing is visible until the swap
+		result, err := in.Call(ctx, "get-version")
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprint(version-1), result.PrintStr())
+
+		in.ReplaceGlobals(next)
+	}
+	wg.Wait()
+
+	result, err := in.Call(ctx, "get-version")
+	require.NoError(t, err)
+	require.Equal(t, "20", result.PrintStr())
+
+	// a failed reload leaves the globals alone
+	next := in.Fork()
+	_, err = next.Eval(ctx, "(define version 21) (undefined-function)")
+	require.Error(t, err)
+	result, err = in.Call(ctx, "get-version")
+	require.NoError(t, err)
+	require.Equal(t, "20", result.PrintStr())
+}
+
+func TestSpawn(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	readEval(bindings, `(load "lang/core.lisp")`)
+
+	require.Equal(t, "3", readEvalPrintNoErr(bindings, "(await (spawn (+ 1 2)))"))
+	require.Equal(t, "(2 3 4)", readEvalPrintNoErr(bindings, `
+      (let ((futures (mapcar (lambda (x) (spawn (+ x 1))) (list 1 2 3))))
+        (mapcar (lambda (f) (await f)) futures))`))
+	require.Equal(t, "#<go:*interpreter.Future>", readEvalPrintNoErr(bindings, "(spawn 1)"))
+
+	// errors are raised by await
+	_, err := readEval(bindings, "(await (spawn (undefined-function)))")
+	require.Error(t, err)
+
+	ch := readEvalPrintNoErr(bindings, "(define never (make-chan))")
+	require.Equal(t, "#<go:chan *types.Value>", ch)
+	require.Equal(t, ":timeout", readEvalPrintNoErr(bindings, "(await (spawn (chan-recv never)) 10)"))
+	readEvalPrintNoErr(bindings, "(chan-close never)")
+
+	_, err = readEval(bindings, "(await 1)")
+	require.EqualError(t, err, "await: not a future: 1")
+}
+
+func TestChannels(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	readEval(bindings, `(load "lang/core.lisp")`)
+
+	readEvalPrintNoErr(bindings, "(define ch (make-chan 2))")
+	require.Equal(t, "1", readEvalPrintNoErr(bindings, "(chan-send ch 1)"))
+	readEvalPrintNoErr(bindings, "(chan-send ch 2)")
+	require.Equal(t, "1", readEvalPrintNoErr(bindings, "(chan-recv ch)"))
+	readEvalPrintNoErr(bindings, "(chan-close ch)")
+	require.Equal(t, "2", readEvalPrintNoErr(bindings, "(chan-recv ch)"))
+	require.Equal(t, ":closed", readEvalPrintNoErr(bindings, "(chan-recv ch)"))
+
+	_, err := readEval(bindings, "(chan-send ch 3)")
+	require.EqualError(t, err, "chan-send: send on a closed channel")
+	_, err = readEval(bindings, "(chan-close ch)")
+	require.EqualError(t, err, "chan-close: channel is already closed")
+	_, err = readEval(bindings, "(chan-recv 1)")
+	require.EqualError(t, err, "chan-recv: not a channel: 1")
+
+	// a producer and a consumer
+	require.Equal(t, "6", readEvalPrintNoErr(bindings, `
+      (let ((numbers (make-chan)))
+        (spawn (mapcar (lambda (x) (chan-send numbers x)) (list 1 2 3))
+               (chan-close numbers))
+        (let ((sum (lambda (acc)
+                     (let ((x (chan-recv numbers)))
+                       (if (= x :closed) acc (sum (+ acc x)))))))
+          (sum 0)))`))
+}
+
+func TestSelect(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	readEval(bindings, `(load "lang/core.lisp")`)
+
+	readEvalPrintNoErr(bindings, "(define a (make-chan 1))")
+	readEvalPrintNoErr(bindings, "(define b (make-chan 1))")
+
+	require.Equal(t, ":nothing", readEvalPrintNoErr(bindings, "(select ((recv a) :a) ((recv b) :b) (default :nothing))"))
+
+	readEvalPrintNoErr(bindings, "(chan-send b 42)")
+	require.Equal(t, "(:b 42)", readEvalPrintNoErr(bindings, "(select ((recv a x) (list :a x)) ((recv b x) (list :b x)))"))
+
+	require.Equal(t, ":sent", readEvalPrintNoErr(bindings, "(select ((send a (+ 1 2)) :sent) (default :full))"))
+	require.Equal(t, ":full", readEvalPrintNoErr(bindings, "(select ((send a 4) :sent) (default :full))"))
+	require.Equal(t, "3", readEvalPrintNoErr(bindings, "(chan-recv a)"))
+
+	// blocks until something is ready
+	require.Equal(t, "7", readEvalPrintNoErr(bindings, "(progn (spawn (chan-send b 7)) (select ((recv a x) x) ((recv b x) x)))"))
+
+	_, err := readEval(bindings, "(select ((receive a) 1))")
+	require.Error(t, err)
+}
+
+func TestSpawnCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	in := interpreter.New()
+
+	done := make(chan error)
+	go func() {
+		_, err := in.Eval(ctx, "(await (spawn (chan-recv (make-chan))))")
+		done <- err
+	}()
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"nondv.io/glisp/interpreter"
+)
+
+func TestDestructuringLet(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	readEval(bindings, `(load "lang/core.lisp")`)
+
+	require.Equal(t, "(1 2 3)", readEvalPrintNoErr(bindings, "(let (((a (b c)) (list 1 (list 2 3)))) (list a b c))"))
+	require.Equal(t, "(1 (2 3))", readEvalPrintNoErr(bindings, "(let (((a . rest) (list 1 2 3))) (list a rest))"))
+	require.Equal(t, "(1 ())", readEvalPrintNoErr(bindings, "(let (((a . rest) (list 1))) (list a rest))"))
+
+	// later declarations see earlier ones
+	require.Equal(t, "3", readEvalPrintNoErr(bindings, "(let (((a b) (list 1 2)) (c (+ a b))) c)"))
+}
+
+func TestDestructuringMaps(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	readEval(bindings, `(load "lang/core.lisp")`)
+
+	require.Equal(t, `("/" 1)`, readEvalPrintNoErr(bindings, `(let (({"path" p :id id} {"path" "/" :id 1})) (list p id))`))
+	require.Equal(t, `("/" ())`, readEvalPrintNoErr(bindings, `(let (({"path" p :id id} #{"path" "/"})) (list p id))`))
+
+	// alists
+	code := `(let (({"path" p "query" {"name" name}}
+	               (list (cons "path" "/hello")
+	                     (cons "query" (list (cons "name" "bob"))))))
+	           (list p name))`
+	require.Equal(t, `("/hello" "bob")`, readEvalPrintNoErr(bindings, code))
+}
+
+func TestDestructuringParams(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	readEval(bindings, `(load "lang/core.lisp")`)
+
+	code := "((lambda ((a b) {:c c} . rest) (list a b c rest)) (list 1 2) {:c 3} 4 5)"
+	require.Equal(t, "(1 2 3 (4 5))", readEvalPrintNoErr(bindings, code))
+
+	// not compiled, but compiled code still gets the same result
+	code = "((lambda ((a b)) (+ a b)) (list 1 2))"
+	require.Equal(t, "3", compileRunPrintNoErr(bindings, code))
+	require.Equal(t, "3", vmRunPrintNoErr(bindings, code))
+}
+
+func TestDestructuringErrors(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	readEval(bindings, `(load "lang/core.lisp")`)
+
+	_, err := readEval(bindings, "(let (((a (b c)) (list 1 (list 2)))) a)")
+	require.EqualError(t, err, "pattern (a (b c)) doesn't match (1 (2)): not enough elements")
+
+	_, err = readEval(bindings, "(let (((a) (list 1 2))) a)")
+	require.EqualError(t, err, "pattern (a) doesn't match (1 2): too many elements")
+
+	_, err = readEval(bindings, "((lambda ((a b)) a) 1)")
+	require.EqualError(t, err, "pattern (a b) doesn't match 1: 1 is not a list")
+
+	_, err = readEval(bindings, `(let (({"a" a} 1)) a)`)
+	require.EqualError(t, err, `pattern {"a" a} doesn't match 1: 1 is not a hash map, persistent map or alist`)
+
+	_, err = readEval(bindings, "(let (((a 1) (list 1 1))) a)")
+	require.NotNil(t, err)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"nondv.io/glisp/interpreter"
+	. "nondv.io/glisp/types"
+)
+
+func TestInterpreter(t *testing.T) {
+	ctx := context.Background()
+	in := interpreter.New()
+
+	result, err := in.Eval(ctx, "(define double (lambda (x) (+ x x))) (double 2)")
+	require.NoError(t, err)
+	require.Equal(t, "4", result.PrintStr())
+
+	in.Define("answer", BuildInteger(42))
+	value, found := in.Get("answer")
+	require.True(t, found)
+	require.Equal(t, "42", value.PrintStr())
+	_, found = in.Get("undefined")
+	require.False(t, found)
+
+	result, err = in.Call(ctx, "double", BuildInteger(21))
+	require.NoError(t, err)
+	require.Equal(t, "42", result.PrintStr())
+
+	// arguments aren't evaluated
+	_, err = in.Eval(ctx, "(define id (lambda (x) x))")
+	require.NoError(t, err)
+	result, err = in.Call(ctx, "id", BuildCons(BuildSymbol("undefined"), BuildEmptyList()))
+	require.NoError(t, err)
+	require.Equal(t, "(undefined)", result.PrintStr())
+
+	in.Define("repeat", WrapGoFunc(strings.Repeat))
+	result, err = in.Eval(ctx, `(repeat "ab" (+ 1 2))`)
+	require.NoError(t, err)
+	require.Equal(t, `"ababab"`, result.PrintStr())
+
+	_, err = in.Call(ctx, "undefined")
+	require.NotNil(t, err)
+	_, err = in.Eval(ctx, "(")
+	require.NotNil(t, err)
+}
+
+// Call gives the same results with compiled lambdas
+func TestInterpreterCompiler(t *testing.T) {
+	ctx := context.Background()
+	in := interpreter.New(interpreter.WithCompiler(), interpreter.WithLimits(interpreter.Limits{MaxSteps: 1000}))
+
+	_, err := in.Eval(ctx, `
+      (define count (lambda (n) (if (= n 0) 0 (+ 1 (count (+ n -1))))))
+      (define bump (lambda (n) (set! n (+ n 1)) n))
+      (define quoted (lambda ARGS ARGS))`)
+	require.NoError(t, err)
+
+	result, err := in.Call(ctx, "count", BuildInteger(10))
+	require.NoError(t, err)
+	require.Equal(t, "10", result.PrintStr())
+
+	// the arguments are values, set! doesn't change them
+	args := []*Value{BuildInteger(1)}
+	result, err = in.Call(ctx, "bump", args...)
+	require.NoError(t, err)
+	require.Equal(t, "2", result.PrintStr())
+	require.Equal(t, "1", args[0].PrintStr())
+
+	// fexprs aren't compiled
+	result, err = in.Call(ctx, "quoted", BuildInteger(1), BuildInteger(2))
+	require.NoError(t, err)
+	require.Equal(t, "(1 2)", result.PrintStr())
+
+	_, err = in.Call(ctx, "count")
+	require.EqualError(t, err, "too many/not enough arguments")
+	_, err = in.Call(ctx, "count", BuildInteger(1000))
+	require.Equal(t, &LimitExceededError{Limit: "max steps"}, err)
+}
+
+func TestInterpreterOutput(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	in := interpreter.New(interpreter.WithStdout(&stdout), interpreter.WithStderr(&stderr))
+
+	result, err := in.Eval(context.Background(), `(print 1 "two") (eprint :three)`)
+	require.NoError(t, err)
+	require.Equal(t, ":three", result.PrintStr())
+	require.Equal(t, "1\n\"two\"\n", stdout.String())
+	require.Equal(t, ":three\n", stderr.String())
+}
+
+func TestInterpreterLimits(t *testing.T) {
+	ctx := context.Background()
+	loop := "(define loop (lambda (n) (loop (+ n 1)))) (loop 0)"
+
+	in := interpreter.New(interpreter.WithLimits(interpreter.Limits{MaxDepth: 100}))
+	_, err := in.Eval(ctx, loop)
+	require.Equal(t, &LimitExceededError{Limit: "max depth"}, err)
+
+	in = interpreter.New(interpreter.WithLimits(interpreter.Limits{MaxSteps: 1000}))
+	_, err = in.Eval(ctx, "(define count (lambda (n) (if (= n 0) 0 (count (+ n -1))))) (count 10)")
+	require.NoError(t, err)
+	_, err = in.Eval(ctx, "(count 1000)")
+	require.Equal(t, &LimitExceededError{Limit: "max steps"}, err)
+
+	// limits are per call
+	_, err = in.Eval(ctx, "(count 10)")
+	require.NoError(t, err)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = interpreter.New().Eval(cancelled, loop)
+	require.Equal(t, context.Canceled, err)
+}
+
+func TestInterpreterLoadPath(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lib.lisp"), []byte("(define from-lib 1)"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.lisp"), []byte(`(load "lib.lisp") (+ from-lib 1)`), 0644))
+
+	in := interpreter.New(interpreter.WithLoadPath("lang", dir))
+	result, err := in.EvalFile(ctx, "main.lisp")
+	require.NoError(t, err)
+	require.Equal(t, "2", result.PrintStr())
+
+	_, err = in.Eval(ctx, `(load "core.lisp")`)
+	require.NoError(t, err)
+
+	_, err = in.EvalFile(ctx, "missing.lisp")
+	require.NotNil(t, err)
+}
+
+// The hook runs before the file is read, so whatever it records (e.g. the
+// modification time) is never newer than the contents evaluated
+func TestInterpreterFileHook(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.lisp")
+	require.NoError(t, os.WriteFile(path, []byte("1"), 0644))
+
+	var hooked []string
+	in := interpreter.New(interpreter.WithLoadPath(dir), interpreter.WithFileHook(func(path string) {
+		hooked = append(hooked, path)
+		if len(hooked) == 1 {
+			require.NoError(t, os.WriteFile(path, []byte("2"), 0644))
+		}
+	}))
+
+	result, err := in.EvalFile(context.Background(), "main.lisp")
+	require.NoError(t, err)
+	require.Equal(t, "2", result.PrintStr())
+	require.Equal(t, []string{path}, hooked)
+
+	// missing files are reported too
+	_, err = in.EvalFile(context.Background(), "missing.lisp")
+	require.Error(t, err)
+	require.Equal(t, "missing.lisp", hooked[1])
+}
+
+func TestInterpreterSandbox(t *testing.T) {
+	ctx := context.Background()
+	in := interpreter.New(interpreter.WithLoadPath("lang"), interpreter.WithSandbox())
+
+	_, err := in.Eval(ctx, `(load "core.lisp")`)
+	require.NoError(t, err)
+
+	for _, path := range []string{"../main.go", "/etc/passwd", "lang/../../x"} {
+		_, err = in.EvalFile(ctx, path)
+		require.NotNil(t, err, path)
+		_, err = in.Eval(ctx, `(load "`+path+`")`)
+		require.NotNil(t, err, path)
+		// the free functions too
+		_, err = readEval(in.Bindings(), `(load "`+path+`")`)
+		require.NotNil(t, err, path)
+	}
+	_, err = readEval(in.Bindings(), `(load "core.lisp")`)
+	require.NoError(t, err)
+	outside := filepath.Join(t.TempDir(), "valid.lisp")
+	require.NoError(t, os.WriteFile(outside, []byte("1"), 0644))
+	_, err = readEval(in.Bindings(), `(load "`+outside+`")`)
+	require.NotNil(t, err)
+
+	_, err = interpreter.New(interpreter.WithSandbox()).Eval(ctx, `(load "lang/core.lisp")`)
+	require.NotNil(t, err)
+}
+
+func TestInterpreterGoType(t *testing.T) {
+	ctx := context.Background()
+	var out strings.Builder
+	in := interpreter.New(interpreter.WithGoType("builder", (**strings.Builder)(nil), map[string]any{
+		"write": func(b *strings.Builder, s string) { b.WriteString(s) },
+	}))
+
+	writer, err := FromGo(&out)
+	require.NoError(t, err)
+	in.Define("out", writer)
+
+	result, err := in.Eval(ctx, `(builder/write out "hi") (builder/write out "!") out`)
+	require.NoError(t, err)
+	require.Equal(t, "hi!", out.String())
+	require.Equal(t, "#<go:*strings.Builder>", result.PrintStr())
+
+	result, err = in.Eval(ctx, "(= out out)")
+	require.NoError(t, err)
+	require.Equal(t, "#t", result.PrintStr())
+}
@@ -1,22 +1,29 @@
+(require "lang/core.lisp")
+(require "lang/alist.lisp")
+(import alist :as a)
+
 (define router
-        (lambda ()
-          (let ((path (alist/get "path" request-data)))
-            (print (+ (alist/get "method" request-data) " " path))
+        (lambda (request-data)
+          (let (({"path" path "method" method "query" {"name" name-param} "writer" w} request-data))
+            (print (+ method " " path))
 
-            (cond
-             ((= path "/hello")
-              (let ((name-param (->> request-data
-                                     (alist/get "query")
-                                     (alist/get "name"))))
-                (if name-param
-                    (response 200 (+ "Hello, " name-param))
-                    (response 400 "Provide `name=` parameter"))))
-             ("else"
-              (response 200 "It works! Try /hello"))))))
+            (match (list method path)
+              ((_ "/hello") :when name-param
+               (response 200 (+ "Hello, " name-param)))
+              ((_ "/hello")
+               (response 400 "Provide `name=` parameter"))
+              ((_ "/stream")
+               ;; writing directly instead of returning a response
+               (http/set-header w "Content-Type" "text/plain")
+               (http/write w "Streaming ")
+               (http/write w "works!")
+               nil)
+              (_
+               (response 200 "It works! Try /hello"))))))
 
 
 (define response
         (lambda (status body)
           (->> ()
-               (alist/set "body" body)
-               (alist/set "status" status))))
+               (a/set "body" body)
+               (a/set "status" status))))
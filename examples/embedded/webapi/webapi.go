@@ -2,12 +2,14 @@ package main
 
 import (
 	// _ "embed"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 
 	"nondv.io/glisp/interpreter"
+	"nondv.io/glisp/reload"
 	. "nondv.io/glisp/types"
 )
 
@@ -15,85 +17,113 @@ import (
 // var routerCode string
 
 func main() {
-	pathToRouter := "examples/embedded/webapi/router.lisp"
-	routerCode, err := os.ReadFile(pathToRouter)
-	routerCodeFileStats, err := os.Stat(pathToRouter)
-
+	ctx := context.Background()
+	in, reloader, err := newInterpreter(ctx, os.Stdout, reload.OnReload(func(changed []string) {
+		fmt.Println("Reloaded:", changed)
+	}), reload.OnError(func(err error) {
+		fmt.Println("Couldn't reload:", err)
+	}))
 	if err != nil {
 		panic(err)
 	}
-
-	baseBindings := interpreter.BuildBaseBindings()
-	interpreter.ReadEval(baseBindings, `(load "lang/core.lisp")`)
-	interpreter.ReadEval(baseBindings, `(load "lang/alist.lisp")`)
-	interpreter.ReadEvalAll(baseBindings, string(routerCode))
+	go reloader.Run(ctx)
 
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		// auto-reloading
-		fileStats, err := os.Stat(pathToRouter)
-		if err == nil && fileStats.ModTime().After(routerCodeFileStats.ModTime()) {
-			routerCode, err = os.ReadFile(pathToRouter)
-			if err == nil {
-				fmt.Println("Reloading router.lisp")
-				routerCodeFileStats = fileStats
-				interpreter.ReadEvalAll(baseBindings, string(routerCode))
-			}
-		}
-
-		handle(baseBindings, w, r)
+		handle(in, w, r)
 	})
 	fmt.Println("Starting server at http://localhost:8080")
 	http.ListenAndServe(":8080", nil)
 }
 
-func handle(baseBindings *Bindings, w http.ResponseWriter, r *http.Request) {
-	bindings := baseBindings.AssocSym("request-data", prepareRequestData(r))
-	result, err := interpreter.ReadEval(bindings, "(router)")
+const pathToRouter = "examples/embedded/webapi/router.lisp"
+
+// The interpreter with the router loaded and a reloader watching it (and the
+// files it requires). The router logs requests to stdout
+func newInterpreter(ctx context.Context, stdout io.Writer, options ...reload.Option) (*interpreter.Interpreter, *reload.Reloader, error) {
+	in := interpreter.New(interpreter.WithStdout(stdout), interpreter.WithCompiler(), interpreter.WithGoType("http", (*http.ResponseWriter)(nil), map[string]any{
+		"write": func(w http.ResponseWriter, s string) error {
+			_, err := io.WriteString(w, s)
+			return err
+		},
+		"set-header": func(w http.ResponseWriter, key string, value string) {
+			w.Header().Set(key, value)
+		},
+		"set-status": func(w http.ResponseWriter, status int) {
+			w.WriteHeader(status)
+		},
+	}))
+
+	reloader := reload.New(in, []string{pathToRouter}, options...)
+	if err := reloader.Load(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	return in, reloader, nil
+}
+
+// What router gets. It can either return a response or write to Writer with
+// the http/ natives and return ()
+type request struct {
+	Path   string              `glisp:"path"`
+	Method string              `glisp:"method"`
+	Body   string              `glisp:"body,omitempty"`
+	Query  map[string]string   `glisp:"query"`
+	Writer http.ResponseWriter `glisp:"writer"`
+}
+
+// What router returns
+type response struct {
+	Status int    `glisp:"status"`
+	Body   string `glisp:"body"`
+}
 
+func handle(in *interpreter.Interpreter, w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	requestData, err := FromGo(prepareRequest(w, r))
 	if err != nil {
+		fmt.Fprintln(os.Stderr, "request:", err)
 		w.WriteHeader(500)
 		fmt.Fprint(w, "Something went wrong")
 		return
 	}
 
-	bindings = bindings.AssocSym("result", result)
-	body, _ := interpreter.ReadEval(bindings, `(alist/get "body" result)`)
-	if !body.IsString() {
+	result, err := in.Call(ctx, "router", requestData)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "router:", err)
 		w.WriteHeader(500)
 		fmt.Fprint(w, "Something went wrong")
 		return
 	}
 
-	status, _ := interpreter.ReadEval(bindings, `(alist/get "status" result)`)
-	if status.IsInteger() {
-		w.WriteHeader(status.ToInt())
+	var resp response
+	if err := ToGo(result, &resp); err != nil {
+		fmt.Fprintln(os.Stderr, "router response:", err)
+		w.WriteHeader(500)
+		fmt.Fprint(w, "Something went wrong")
+		return
 	}
 
-	fmt.Fprint(w, body.ToStr())
+	if resp.Status != 0 {
+		w.WriteHeader(resp.Status)
+	}
+	fmt.Fprint(w, resp.Body)
 }
 
-func prepareRequestData(r *http.Request) *Value {
-	result := BuildEmptyList()
-	result = alistAssoc(result, "path", BuildString(r.URL.Path))
-	result = alistAssoc(result, "method", BuildString(r.Method))
-
+func prepareRequest(w http.ResponseWriter, r *http.Request) request {
 	body, _ := io.ReadAll(r.Body)
-	if string(body) != "" {
-		result = alistAssoc(result, "body", BuildString(string(body)))
-	}
 
-	query := BuildEmptyList()
+	query := map[string]string{}
 	for key, values := range r.URL.Query() {
 		if len(values) > 0 {
-			val := BuildString(values[len(values)-1])
-			query = alistAssoc(query, key, val)
+			query[key] = values[len(values)-1]
 		}
 	}
-	result = alistAssoc(result, "query", query)
 
-	return result
-}
-
-func alistAssoc(alist *Value, key string, value *Value) *Value {
-	return BuildCons(BuildCons(BuildString(key), value), alist)
+	return request{
+		Path:   r.URL.Path,
+		Method: r.Method,
+		Body:   string(body),
+		Query:  query,
+		Writer: w,
+	}
 }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+)
+
+func BenchmarkHandle(b *testing.B) {
+	// paths in router.lisp and core.lisp are relative to the repo root
+	wd, _ := os.Getwd()
+	if err := os.Chdir("../../.."); err != nil {
+		b.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	in, _, err := newInterpreter(context.Background(), io.Discard)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		handle(in, w, httptest.NewRequest("GET", "/hello?name=bench", nil))
+
+		if w.Code != 200 || w.Body.String() != "Hello, bench" {
+			b.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+		}
+	}
+}
+
+// Run with -race
+func TestConcurrentHandleAndReload(t *testing.T) {
+	wd, _ := os.Getwd()
+	if err := os.Chdir("../../.."); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	in, reloader, err := newInterpreter(context.Background(), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				w := httptest.NewRecorder()
+				handle(in, w, httptest.NewRequest("GET", "/hello?name=race", nil))
+				if w.Code != 200 || w.Body.String() != "Hello, race" {
+					t.Errorf("unexpected response %d %q", w.Code, w.Body.String())
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 20; i++ {
+		if err := reloader.Reload(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+}
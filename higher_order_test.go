@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"nondv.io/glisp/interpreter"
+)
+
+func TestApply(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	readEval(bindings, `(load "lang/core.lisp")`)
+
+	require.Equal(t, "6", readEvalPrintNoErr(bindings, "(apply + (list 1 2 3))"))
+	require.Equal(t, "10", readEvalPrintNoErr(bindings, "(apply + 1 2 (list 3 4))"))
+	require.Equal(t, "0", readEvalPrintNoErr(bindings, "(apply + ())"))
+	require.Equal(t, "(1 2)", readEvalPrintNoErr(bindings, "(apply (lambda (a &rest b) (cons a b)) (list 1 2))"))
+
+	// values aren't evaluated again
+	require.Equal(t, "(+ 1 2)", readEvalPrintNoErr(bindings, "(apply (lambda (x) x) (list (list (quote +) 1 2)))"))
+	require.Equal(t, "undefined-symbol", readEvalPrintNoErr(bindings, "(apply (lambda (x) x) (list (quote undefined-symbol)))"))
+
+	// fexprs get the values as their argument list
+	require.Equal(t, "((+ 1 2) 3)", readEvalPrintNoErr(bindings, "(apply (lambda ARGS ARGS) (list (list (quote +) 1 2) 3))"))
+
+	for _, code := range []string{"(apply +)", "(apply + 1)", "(apply 1 ())", "(apply (lambda (x) x) ())"} {
+		_, err := readEval(bindings, code)
+		require.NotNil(t, err, code)
+	}
+}
+
+func TestFuncall(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	readEval(bindings, `(load "lang/core.lisp")`)
+
+	require.Equal(t, "3", readEvalPrintNoErr(bindings, "(funcall + 1 2)"))
+	require.Equal(t, "(1 2)", readEvalPrintNoErr(bindings, "(let ((data (list 1 2))) (funcall (lambda (x) x) data))"))
+	require.Equal(t, "(quote x)", readEvalPrintNoErr(bindings, "(funcall identity (list (quote quote) (quote x)))"))
+
+	_, err := readEval(bindings, "(funcall)")
+	require.NotNil(t, err)
+
+	// special forms would evaluate the values again
+	for _, code := range []string{"(funcall if (quote undefined) 1 2)", "(apply define (list (quote x) (quote y)))", "(funcall (compose if) 1)"} {
+		_, err := readEval(bindings, code)
+		require.EqualError(t, err, "Special forms can't be called on values", code)
+	}
+}
+
+func TestComposePartial(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	readEval(bindings, `(load "lang/core.lisp")`)
+	readEval(bindings, "(define double (lambda (x) (+ x x)))")
+
+	require.Equal(t, "7", readEvalPrintNoErr(bindings, "((compose (partial + 1) double) 3)"))
+	require.Equal(t, "8", readEvalPrintNoErr(bindings, "((compose double double) 2)"))
+	require.Equal(t, "6", readEvalPrintNoErr(bindings, "((compose double +) 1 2)"))
+	require.Equal(t, "5", readEvalPrintNoErr(bindings, "((compose) 5)"))
+	require.Equal(t, "(2 3 4)", readEvalPrintNoErr(bindings, "(mapcar (partial + 1) (list 1 2 3))"))
+	require.Equal(t, "(1 2 3)", readEvalPrintNoErr(bindings, "(mapcar identity (list 1 2 3))"))
+
+	_, err := readEval(bindings, "(partial)")
+	require.NotNil(t, err)
+}
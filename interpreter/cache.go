@@ -0,0 +1,52 @@
+package interpreter
+
+import (
+	"runtime"
+	"sync"
+	"weak"
+
+	. "nondv.io/glisp/types"
+)
+
+// FormCache maps forms (by identity) to things derived from them, e.g.
+// compiled code. It doesn't keep the forms alive: an entry is removed once its
+// form is garbage collected, so lambdas created at runtime or by reloading
+// files don't accumulate. Cached values must not reference their forms.
+//
+// The zero value is ready to use and it's safe for concurrent use.
+type FormCache[T any] struct {
+	entries sync.Map // weak.Pointer[Value] -> T
+}
+
+func (c *FormCache[T]) Load(form *Value) (T, bool) {
+	cached, found := c.entries.Load(weak.Make(form))
+	if !found {
+		var zero T
+		return zero, false
+	}
+
+	return cached.(T), true
+}
+
+// Keeps the existing value if there is one (concurrent callers computed the
+// same thing) and returns the value stored
+func (c *FormCache[T]) Store(form *Value, value T) T {
+	key := weak.Make(form)
+	cached, loaded := c.entries.LoadOrStore(key, value)
+	if !loaded {
+		runtime.AddCleanup(form, func(key weak.Pointer[Value]) { c.entries.Delete(key) }, key)
+	}
+
+	return cached.(T)
+}
+
+// Number of entries, for tests
+func (c *FormCache[T]) Len() int {
+	n := 0
+	c.entries.Range(func(any, any) bool {
+		n++
+		return true
+	})
+
+	return n
+}
@@ -0,0 +1,69 @@
+package interpreter
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"nondv.io/glisp/reader"
+	. "nondv.io/glisp/types"
+)
+
+func TestFormCacheDropsCollectedForms(t *testing.T) {
+	var cache FormCache[int]
+	kept := BuildCons(BuildInteger(1), BuildEmptyList())
+	cache.Store(kept, 1)
+	for i := 0; i < 100; i++ {
+		cache.Store(BuildCons(BuildInteger(i), BuildEmptyList()), i)
+	}
+
+	if cached, found := cache.Load(kept); !found || cached != 1 {
+		t.Fatal("entry is missing")
+	}
+	if cached := cache.Store(kept, 2); cached != 1 {
+		t.Fatal("existing entry was replaced")
+	}
+
+	// cleanups run asynchronously after a collection
+	deadline := time.Now().Add(5 * time.Second)
+	for cache.Len() > 1 && time.Now().Before(deadline) {
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	if cache.Len() != 1 {
+		t.Fatalf("%d entries left", cache.Len())
+	}
+	runtime.KeepAlive(kept)
+}
+
+// Lambdas created at runtime don't stay in the caches
+func TestLambdaCachesDontLeak(t *testing.T) {
+	bindings := BuildBaseBindings()
+	bindings.DefineSym("make-lambda", BuildApplicativeFn(func(*Bindings, *Value) (*Value, error) {
+		return reader.Read("(lambda (x) x)")
+	}))
+	form, err := reader.Read("((make-lambda) 1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := Compile(form)
+	for i := 0; i < 100; i++ {
+		if _, err := code(bindings); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if lambdaCache.Len() == 0 || lambdaListCache.Len() == 0 {
+		t.Fatal("nothing was cached")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for (lambdaCache.Len() > 0 || lambdaListCache.Len() > 0) && time.Now().Before(deadline) {
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	if lambdaCache.Len() != 0 || lambdaListCache.Len() != 0 {
+		t.Fatalf("%d lambdas and %d lambda lists cached", lambdaCache.Len(), lambdaListCache.Len())
+	}
+}
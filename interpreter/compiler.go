@@ -0,0 +1,322 @@
+package interpreter
+
+import (
+	"errors"
+	"slices"
+
+	. "nondv.io/glisp/types"
+)
+
+/*
+ * Optional analysis pass. A form is turned into a tree of Go closures once,
+ * so running it again doesn't re-inspect the form.
+ *
+ * Since any symbol can be rebound (including if and let) and lambdas with a
+ * symbol parameter receive their arguments unevaluated, very little can be
+ * decided in advance:
+ *
+ * - variables bound by compiled let and lambda calls are resolved to frame
+ *   slots. Everything else is looked up dynamically, same as Eval does;
+ * - if and let are compiled only if at runtime the symbols still refer to the
+ *   builtins;
+ * - calls to lambdas with parameter lists and to natives evaluating their
+ *   arguments use compiled arguments. Everything else (fexprs, special forms)
+ *   gets the original forms, exactly like with Eval.
+ */
+
+// Code is a compiled form. Running it gives the same result as Eval on the
+// original form
+type Code func(*Bindings) (*Value, error)
+
+// Scope is the compile-time mirror of the local frames created by compiled
+// code. Shared with the vm package
+type Scope struct {
+	Symbols []*Value
+	Parent  *Scope
+}
+
+type compiledLambda struct {
+	params []*Value
+	body   Code
+}
+
+// lambda -> *compiledLambda
+var lambdaCache FormCache[*compiledLambda]
+
+func Compile(form *Value) Code {
+	return compile(form, nil)
+}
+
+// Frame depth and index of sym (see Bindings.Local), if it's a local
+// variable. Later symbols in a frame shadow earlier ones
+func (s *Scope) Resolve(sym *Value) (int, int, bool) {
+	depth := 0
+	for ; s != nil; s = s.Parent {
+		for i := len(s.Symbols) - 1; i >= 0; i-- {
+			if s.Symbols[i] == sym {
+				return depth, i, true
+			}
+		}
+		depth++
+	}
+
+	return 0, 0, false
+}
+
+func compile(form *Value, s *Scope) Code {
+	if form.IsSelfEvaluating() {
+		return func(*Bindings) (*Value, error) { return form, nil }
+	}
+
+	if form.IsSymbol() {
+		if depth, i, found := s.Resolve(form); found {
+			return func(b *Bindings) (*Value, error) { return b.Local(depth, i), nil }
+		}
+
+		return func(b *Bindings) (*Value, error) {
+			val, found := Lookup(b, form)
+			if !found {
+				return nil, errors.New("Undefined")
+			}
+			return val, nil
+		}
+	}
+
+	if form.IsList() {
+		if form.Car().IsLambdaSymbol() {
+			return func(*Bindings) (*Value, error) { return form, nil }
+		}
+
+		return compileCall(form, s)
+	}
+
+	if form.IsCons() {
+		return func(*Bindings) (*Value, error) { return nil, errImproperArgs }
+	}
+
+	return func(b *Bindings) (*Value, error) { return Eval(b, form) }
+}
+
+func compileCall(form *Value, s *Scope) Code {
+	fn := form.Car()
+	args := form.Cdr()
+	generic := compileGenericCall(fn, args, s)
+
+	if !fn.IsSymbol() {
+		return generic
+	}
+	if _, _, local := s.Resolve(fn); local {
+		return generic
+	}
+
+	var special Code
+	expected := SpecialForm(fn.SymbolName())
+	switch expected {
+	case ifFn:
+		special = compileIf(args, s)
+	case letFn:
+		special = compileLet(args, s)
+	}
+
+	if special == nil {
+		return generic
+	}
+
+	return func(b *Bindings) (*Value, error) {
+		if val, _ := b.Lookup(fn); val == expected {
+			return special(b)
+		}
+		return generic(b)
+	}
+}
+
+func compileGenericCall(fn *Value, args *Value, s *Scope) Code {
+	fnCode := compile(fn, s)
+	argCodes := compileEach(args, s)
+
+	return func(b *Bindings) (*Value, error) {
+		// same limits as Eval, once per call
+		if rt := b.Runtime(); rt != nil {
+			defer rt.Leave()
+			if err := rt.Enter(); err != nil {
+				return nil, err
+			}
+		}
+
+		fnValue, err := fnCode(b)
+		if err != nil {
+			return nil, err
+		}
+
+		if fnValue.IsNativeFn() && fnValue.ToNativeFn().EvalArgs {
+			values, err := runEach(b, argCodes)
+			if err != nil {
+				return nil, err
+			}
+			return fnValue.ToNativeFn().Fn(b, sliceToList(values))
+		}
+
+		if lambda := compileLambda(fnValue); lambda != nil {
+			if len(lambda.params) != len(argCodes) {
+				return nil, errors.New("too many/not enough arguments")
+			}
+
+			values, err := runEach(b, argCodes)
+			if err != nil {
+				return nil, err
+			}
+			return lambda.body(b.Extend(lambda.params, values))
+		}
+
+		return Call(b, fnValue, args)
+	}
+}
+
+// Calls a compiled lambda with values. Counts as a step, like the call in
+// compiled code does
+func callCompiled(b *Bindings, lambda *compiledLambda, values []*Value) (*Value, error) {
+	if rt := b.Runtime(); rt != nil {
+		defer rt.Leave()
+		if err := rt.Enter(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(lambda.params) != len(values) {
+		return nil, errors.New("too many/not enough arguments")
+	}
+
+	// the frame owns its values
+	return lambda.body(b.Extend(lambda.params, slices.Clone(values)))
+}
+
+// Only lambdas with a plain list of symbols as parameters can be compiled
+func compileLambda(fn *Value) *compiledLambda {
+	if !fn.IsCons() || !fn.IsList() || !fn.Car().IsLambdaSymbol() {
+		return nil
+	}
+
+	if cached, found := lambdaCache.Load(fn); found {
+		return cached
+	}
+
+	// nothing is cached for the rest, parsing lambda lists is cached already
+	params, simple := SimpleParams(fn.Cdr().Car())
+	if !simple {
+		return nil
+	}
+
+	return lambdaCache.Store(fn, &compiledLambda{params, compileBody(fn.Cdr().Cdr(), &Scope{params, nil})})
+}
+
+// (if CONDITION THEN ELSE)
+func compileIf(args *Value, s *Scope) Code {
+	if args.ListLength() != 3 {
+		return nil
+	}
+
+	condition := compile(args.Car(), s)
+	thenBranch := compile(args.Cdr().Car(), s)
+	elseBranch := compile(args.Cdr().Cdr().Car(), s)
+
+	return func(b *Bindings) (*Value, error) {
+		conditionVal, err := condition(b)
+		if err != nil {
+			return nil, err
+		}
+
+		if conditionVal.IsTruthy() {
+			return thenBranch(b)
+		}
+		return elseBranch(b)
+	}
+}
+
+// Whether the arguments of let are ((SYMBOL SEXP) ...) BODY..., the only
+// form compiled (by this package and vm)
+func SimpleLet(args *Value) bool {
+	if !args.IsCons() || !args.Car().IsList() {
+		return false
+	}
+
+	for iter := args.Car(); !iter.IsEmptyList(); iter = iter.Cdr() {
+		declaration := iter.Car()
+		if !declaration.IsList() || declaration.ListLength() != 2 || !declaration.Car().IsSymbol() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// (let ((SYMBOL SEXP) ...) BODY...)
+func compileLet(args *Value, s *Scope) Code {
+	if !SimpleLet(args) {
+		return nil
+	}
+
+	var syms []*Value
+	var inits []Code
+	inner := s
+	for iter := args.Car(); !iter.IsEmptyList(); iter = iter.Cdr() {
+		declaration := iter.Car()
+		syms = append(syms, declaration.Car())
+		inits = append(inits, compile(declaration.Cdr().Car(), inner))
+		inner = &Scope{[]*Value{declaration.Car()}, inner}
+	}
+
+	body := compileBody(args.Cdr(), inner)
+
+	return func(b *Bindings) (*Value, error) {
+		letBindings := b
+		for i, init := range inits {
+			value, err := init(letBindings)
+			if err != nil {
+				return nil, err
+			}
+			letBindings = letBindings.Assoc(syms[i], value)
+		}
+
+		return body(letBindings)
+	}
+}
+
+// Runs forms in order and returns the last result
+func compileBody(forms *Value, s *Scope) Code {
+	codes := compileEach(forms, s)
+
+	return func(b *Bindings) (*Value, error) {
+		result := BuildEmptyList()
+		for _, code := range codes {
+			var err error
+			result, err = code(b)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return result, nil
+	}
+}
+
+func compileEach(forms *Value, s *Scope) []Code {
+	var codes []Code
+	for iter := forms; !iter.IsEmptyList(); iter = iter.Cdr() {
+		codes = append(codes, compile(iter.Car(), s))
+	}
+
+	return codes
+}
+
+func runEach(b *Bindings, codes []Code) ([]*Value, error) {
+	values := make([]*Value, len(codes))
+	for i, code := range codes {
+		value, err := code(b)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+
+	return values, nil
+}
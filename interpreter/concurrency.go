@@ -0,0 +1,332 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	. "nondv.io/glisp/types"
+)
+
+/*
+ * Goroutines and channels:
+ *
+ *   (spawn BODY...)            - evaluates BODY in a new goroutine, returns a future
+ *   (await FUTURE)             - waits for the result (errors are re-raised)
+ *   (await FUTURE MS)          - same, but returns :timeout after MS milliseconds
+ *   (make-chan) (make-chan N)  - unbuffered or buffered channel
+ *   (chan-send CH VALUE)       - blocks until received (or buffered)
+ *   (chan-recv CH)             - blocks until a value arrives, :closed if closed
+ *   (chan-close CH)
+ *
+ *   (select
+ *     ((recv CH) BODY...)       - BODY runs if a value was received
+ *     ((recv CH VAR) BODY...)   - with VAR bound to it (:closed if closed)
+ *     ((send CH VALUE) BODY...) - if VALUE was sent
+ *     (default BODY...))        - if nothing else is ready
+ *
+ * Futures and channels are go objects. Spawned code shares the globals (see
+ * Bindings on what's safe) and gets its own runtime with the same context, so
+ * cancelling an evaluation stops everything it spawned. Blocking operations
+ * give up when the context is cancelled.
+ *
+ * Without a runtime (or with one without a context) nothing can be cancelled:
+ * a goroutine blocked on a channel nobody uses any more leaks, and await
+ * without a timeout may never return. ReadEval doesn't install a context of
+ * its own since futures and channels outlive the call that created them (e.g.
+ * in the REPL). Embedders should use Interpreter, which always sets one.
+ */
+
+var (
+	timeoutKeyword = BuildKeyword("timeout")
+	closedKeyword  = BuildKeyword("closed")
+	recvSymbol     = BuildSymbol("recv")
+	sendSymbol     = BuildSymbol("send")
+	defaultSymbol  = BuildSymbol("default")
+)
+
+// Result of spawn. Go code can wait on it too
+type Future struct {
+	done  chan struct{}
+	value *Value
+	err   error
+}
+
+// Waits for the spawned code to finish
+func (f *Future) Wait(ctx context.Context) (*Value, error) {
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Closed when the result is ready
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// The runtime's context. Background (never cancelled) if there is none, see
+// above
+func evalContext(bindings *Bindings) context.Context {
+	if rt := bindings.Runtime(); rt != nil && rt.Context != nil {
+		return rt.Context
+	}
+
+	return context.Background()
+}
+
+func nativeSpawn(bindings *Bindings, args *Value) (*Value, error) {
+	if rt := bindings.Runtime(); rt != nil {
+		bindings = bindings.WithRuntime(rt.Fork())
+	}
+	// spawned code isn't part of the transaction it was spawned from
+	if currentTransaction(bindings) != nil {
+		bindings = bindings.Assoc(transactionSymbol, BuildEmptyList())
+	}
+
+	future := &Future{done: make(chan struct{})}
+	go func() {
+		defer close(future.done)
+		defer func() {
+			if r := recover(); r != nil {
+				future.err = fmt.Errorf("spawn: %v", r)
+			}
+		}()
+
+		future.value, future.err = evalBody(bindings, args)
+	}()
+
+	return BuildGoObject(future), nil
+}
+
+func nativeAwait(bindings *Bindings, args *Value) (*Value, error) {
+	length := args.ListLength()
+	if length != 1 && length != 2 {
+		return nil, errors.New("syntax: (await FUTURE [TIMEOUT-MS])")
+	}
+
+	future, ok := goObjectArg[*Future](args.Car())
+	if !ok {
+		return nil, errors.New("await: not a future: " + args.Car().PrintStr())
+	}
+
+	ctx := evalContext(bindings)
+	if length == 2 {
+		timeout := args.Cdr().Car()
+		if !timeout.IsInteger() {
+			return nil, errors.New("await: timeout must be an integer (milliseconds)")
+		}
+
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout.ToInt())*time.Millisecond)
+		defer cancel()
+	}
+
+	value, err := future.Wait(ctx)
+	if errors.Is(err, context.DeadlineExceeded) && length == 2 && evalContext(bindings).Err() == nil {
+		return timeoutKeyword, nil
+	}
+
+	return value, err
+}
+
+func nativeMakeChan(bindings *Bindings, args *Value) (*Value, error) {
+	size := 0
+	switch args.ListLength() {
+	case 0:
+	case 1:
+		if !args.Car().IsInteger() || args.Car().ToInt() < 0 {
+			return nil, errors.New("make-chan: size must be a non-negative integer")
+		}
+		size = args.Car().ToInt()
+	default:
+		return nil, errors.New("syntax: (make-chan [SIZE])")
+	}
+
+	return BuildGoObject(make(chan *Value, size)), nil
+}
+
+func nativeChanSend(bindings *Bindings, args *Value) (*Value, error) {
+	if args.ListLength() != 2 {
+		return nil, errors.New("syntax: (chan-send CH VALUE)")
+	}
+
+	ch, err := chanArg("chan-send", args.Car())
+	if err != nil {
+		return nil, err
+	}
+
+	value := args.Cdr().Car()
+	cases := []reflect.SelectCase{{Dir: reflect.SelectSend, Chan: reflect.ValueOf(ch), Send: reflect.ValueOf(value)}}
+	if _, _, err := selectWithContext(evalContext(bindings), cases, "chan-send"); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+func nativeChanRecv(bindings *Bindings, args *Value) (*Value, error) {
+	if args.ListLength() != 1 {
+		return nil, errors.New("syntax: (chan-recv CH)")
+	}
+
+	ch, err := chanArg("chan-recv", args.Car())
+	if err != nil {
+		return nil, err
+	}
+
+	cases := []reflect.SelectCase{{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)}}
+	_, value, err := selectWithContext(evalContext(bindings), cases, "chan-recv")
+	return value, err
+}
+
+func nativeChanClose(bindings *Bindings, args *Value) (result *Value, err error) {
+	if args.ListLength() != 1 {
+		return nil, errors.New("syntax: (chan-close CH)")
+	}
+
+	ch, err := chanArg("chan-close", args.Car())
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if recover() != nil {
+			result, err = nil, errors.New("chan-close: channel is already closed")
+		}
+	}()
+	close(ch)
+
+	return BuildEmptyList(), nil
+}
+
+func nativeSelect(bindings *Bindings, args *Value) (*Value, error) {
+	var cases []reflect.SelectCase
+	var clauses []*Value
+	var defaultBody *Value
+
+	for iter := args; !iter.IsEmptyList(); iter = iter.Cdr() {
+		clause := iter.Car()
+		if !clause.IsCons() || !clause.IsList() {
+			return nil, errors.New("select: clause must be (OPERATION BODY...)")
+		}
+
+		if clause.Car() == defaultSymbol {
+			defaultBody = clause.Cdr()
+			continue
+		}
+
+		selectCase, err := selectCase(bindings, clause.Car())
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, selectCase)
+		clauses = append(clauses, clause)
+	}
+
+	if defaultBody != nil {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+	}
+
+	chosen, received, err := selectWithContext(evalContext(bindings), cases, "select")
+	if err != nil {
+		return nil, err
+	}
+
+	if chosen == len(clauses) {
+		return evalBody(bindings, defaultBody)
+	}
+
+	clause := clauses[chosen]
+	operation := clause.Car()
+	if operation.Car() == recvSymbol && operation.ListLength() == 3 {
+		bindings = bindings.Assoc(operation.Cdr().Cdr().Car(), received)
+	}
+
+	return evalBody(bindings, clause.Cdr())
+}
+
+// (recv CH [VAR]) or (send CH VALUE) with CH and VALUE evaluated
+func selectCase(bindings *Bindings, operation *Value) (reflect.SelectCase, error) {
+	syntaxError := errors.New("select: operation must be (recv CH [VAR]) or (send CH VALUE)")
+	if !operation.IsCons() || !operation.IsList() {
+		return reflect.SelectCase{}, syntaxError
+	}
+
+	length := operation.ListLength()
+	isRecv := operation.Car() == recvSymbol && (length == 2 || length == 3 && operation.Cdr().Cdr().Car().IsSymbol())
+	isSend := operation.Car() == sendSymbol && length == 3
+	if !isRecv && !isSend {
+		return reflect.SelectCase{}, syntaxError
+	}
+
+	chValue, err := Eval(bindings, operation.Cdr().Car())
+	if err != nil {
+		return reflect.SelectCase{}, err
+	}
+	ch, err := chanArg("select", chValue)
+	if err != nil {
+		return reflect.SelectCase{}, err
+	}
+
+	if isRecv {
+		return reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)}, nil
+	}
+
+	value, err := Eval(bindings, operation.Cdr().Cdr().Car())
+	if err != nil {
+		return reflect.SelectCase{}, err
+	}
+	return reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(ch), Send: reflect.ValueOf(value)}, nil
+}
+
+// reflect.Select that also gives up when ctx is done. Received values are
+// :closed for closed channels and () for sends
+func selectWithContext(ctx context.Context, cases []reflect.SelectCase, name string) (chosen int, received *Value, err error) {
+	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())})
+
+	defer func() {
+		if recover() != nil {
+			err = errors.New(name + ": send on a closed channel")
+		}
+	}()
+
+	chosen, value, ok := reflect.Select(cases)
+	if chosen == len(cases)-1 {
+		return 0, nil, ctx.Err()
+	}
+
+	switch {
+	case cases[chosen].Dir != reflect.SelectRecv:
+		received = BuildEmptyList()
+	case !ok:
+		received = closedKeyword
+	default:
+		received = value.Interface().(*Value)
+	}
+
+	return chosen, received, nil
+}
+
+func chanArg(name string, v *Value) (chan *Value, error) {
+	ch, ok := goObjectArg[chan *Value](v)
+	if !ok {
+		return nil, errors.New(name + ": not a channel: " + v.PrintStr())
+	}
+
+	return ch, nil
+}
+
+func goObjectArg[T any](v *Value) (T, bool) {
+	if !v.IsGoObject() {
+		var zero T
+		return zero, false
+	}
+
+	object, ok := v.ToGoObject().(T)
+	return object, ok
+}
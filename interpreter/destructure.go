@@ -0,0 +1,147 @@
+package interpreter
+
+import (
+	"errors"
+
+	. "nondv.io/glisp/types"
+)
+
+/*
+ * Destructuring patterns for let and lambda parameters:
+ *
+ *   x               - binds the whole value
+ *   (a (b c))       - a list of exactly two elements, the second one a list of two
+ *   (a b . rest)    - at least two elements, rest is the remaining list
+ *   ()              - only matches ()
+ *   {"path" p :id id}  - looks the keys up in a hash map, persistent map or
+ *                    alist. Missing keys bind ()
+ *
+ * Keys in map patterns aren't evaluated.
+ */
+
+// A pattern is anything destructure accepts. Checked in advance so a bad
+// lambda list is reported even if it isn't called with a matching value
+func isPattern(pattern *Value) bool {
+	if pattern.IsSymbol() || pattern.IsEmptyList() {
+		return true
+	}
+
+	if pattern.IsCons() {
+		return isPattern(pattern.Car()) && isPattern(pattern.Cdr())
+	}
+
+	if pattern.IsHashMap() {
+		for _, sub := range pattern.ToHashMap().Values() {
+			if !isPattern(sub) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// Appends the symbols pattern binds to syms
+func patternSymbols(pattern *Value, syms []*Value) []*Value {
+	switch {
+	case pattern.IsSymbol():
+		return append(syms, pattern)
+	case pattern.IsCons():
+		return patternSymbols(pattern.Cdr(), patternSymbols(pattern.Car(), syms))
+	case pattern.IsHashMap():
+		for _, sub := range pattern.ToHashMap().Values() {
+			syms = patternSymbols(sub, syms)
+		}
+	}
+
+	return syms
+}
+
+// Appends symbols from pattern and the matching parts of value to syms and
+// vals
+func destructure(pattern *Value, value *Value, syms []*Value, vals []*Value) ([]*Value, []*Value, error) {
+	syms, vals, reason := destructureInto(pattern, value, syms, vals)
+	if reason != "" {
+		return nil, nil, errors.New("pattern " + pattern.PrintStr() + " doesn't match " + value.PrintStr() + ": " + reason)
+	}
+
+	return syms, vals, nil
+}
+
+// Returns a non-empty reason if value doesn't match
+func destructureInto(pattern *Value, value *Value, syms []*Value, vals []*Value) ([]*Value, []*Value, string) {
+	if pattern.IsSymbol() {
+		return append(syms, pattern), append(vals, value), ""
+	}
+
+	if pattern.IsEmptyList() {
+		if !value.IsEmptyList() {
+			return nil, nil, "too many elements"
+		}
+		return syms, vals, ""
+	}
+
+	if pattern.IsCons() {
+		if !value.IsCons() {
+			if value.IsEmptyList() {
+				return nil, nil, "not enough elements"
+			}
+			return nil, nil, value.PrintStr() + " is not a list"
+		}
+
+		syms, vals, reason := destructureInto(pattern.Car(), value.Car(), syms, vals)
+		if reason != "" {
+			return nil, nil, reason
+		}
+		return destructureInto(pattern.Cdr(), value.Cdr(), syms, vals)
+	}
+
+	if pattern.IsHashMap() {
+		keys, subpatterns := pattern.ToHashMap().Keys(), pattern.ToHashMap().Values()
+		for i, key := range keys {
+			found, ok := lookupKey(value, key)
+			if !ok {
+				return nil, nil, value.PrintStr() + " is not a hash map, persistent map or alist"
+			}
+
+			var reason string
+			syms, vals, reason = destructureInto(subpatterns[i], found, syms, vals)
+			if reason != "" {
+				return nil, nil, reason
+			}
+		}
+		return syms, vals, ""
+	}
+
+	return nil, nil, pattern.PrintStr() + " is not a valid pattern"
+}
+
+// Returns () for missing keys. false if value can't be looked up by key
+func lookupKey(value *Value, key *Value) (*Value, bool) {
+	switch {
+	case value.IsHashMap():
+		if found, exists := value.ToHashMap().Get(key); exists {
+			return found, true
+		}
+		return BuildEmptyList(), true
+	case value.IsPMap():
+		if found, exists := value.ToPMap().Get(key); exists {
+			return found, true
+		}
+		return BuildEmptyList(), true
+	case value.IsList():
+		for iter := value; !iter.IsEmptyList(); iter = iter.Cdr() {
+			pair := iter.Car()
+			if !pair.IsCons() {
+				return nil, false
+			}
+			if Equal(pair.Car(), key) {
+				return pair.Cdr(), true
+			}
+		}
+		return BuildEmptyList(), true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,248 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "nondv.io/glisp/types"
+)
+
+// Interpreter bundles base bindings with the settings every evaluation should
+// use. It's the easiest way to embed glisp:
+//
+//	in := interpreter.New(interpreter.WithLoadPath("lisp"), interpreter.WithSandbox())
+//	_, err := in.EvalFile(ctx, "router.lisp")
+//	result, err := in.Call(ctx, "router", request)
+//
+// Every Eval/EvalFile/Call gets its own Runtime, so limits apply per call.
+// An Interpreter can be used from several goroutines at once (see Bindings for
+// the details)
+type Interpreter struct {
+	bindings *Bindings
+	stdout   io.Writer
+	stderr   io.Writer
+	loadPath []string
+	sandbox  bool
+	limits   Limits
+	fileHook func(path string)
+	compile  bool
+}
+
+// Zero means unlimited
+type Limits struct {
+	// Number of evaluated forms
+	MaxSteps int
+	// Nesting of evaluated forms, roughly the Go stack depth
+	MaxDepth int
+}
+
+type Option func(*Interpreter)
+
+// Where print goes. os.Stdout by default
+func WithStdout(w io.Writer) Option {
+	return func(in *Interpreter) { in.stdout = w }
+}
+
+// Where eprint goes. os.Stderr by default
+func WithStderr(w io.Writer) Option {
+	return func(in *Interpreter) { in.stderr = w }
+}
+
+// Directories relative paths given to load and EvalFile are looked up in, in
+// order. By default they're relative to the working directory
+func WithLoadPath(dirs ...string) Option {
+	return func(in *Interpreter) { in.loadPath = append(in.loadPath, dirs...) }
+}
+
+// Only files inside the load path can be loaded. Absolute paths and paths
+// leading outside of it are rejected. Without a load path nothing can be
+// loaded
+func WithSandbox() Option {
+	return func(in *Interpreter) { in.sandbox = true }
+}
+
+func WithLimits(limits Limits) Option {
+	return func(in *Interpreter) { in.limits = limits }
+}
+
+// Calls f with the path of every file read by EvalFile and load (after
+// resolving it against the load path), right before reading it. Used to track
+// dependencies
+func WithFileHook(f func(path string)) Option {
+	return func(in *Interpreter) { in.fileHook = f }
+}
+
+// Call runs lambdas with parameter lists compiled (see Compile) instead of
+// evaluating their bodies. The compiled code is cached until the lambda is
+// garbage collected, so it pays off for functions called many times, e.g. a
+// request handler
+func WithCompiler() Option {
+	return func(in *Interpreter) { in.compile = true }
+}
+
+// Use b instead of BuildBaseBindings(), e.g. bindings restored from an image
+// (see the snapshot package). Should come before options defining globals
+func WithBindings(b *Bindings) Option {
+	return func(in *Interpreter) { in.bindings = b }
+}
+
+// Registers a Go object type and defines natives for its methods, see
+// RegisterGoType. Panics if it fails, like regexp.MustCompile, since the
+// arguments are usually constants
+func WithGoType(prefix string, sample any, methods map[string]any) Option {
+	return func(in *Interpreter) {
+		natives, err := RegisterGoType(prefix, sample, methods)
+		if err != nil {
+			panic(err)
+		}
+		for name, native := range natives {
+			in.Define(name, native)
+		}
+	}
+}
+
+func New(options ...Option) *Interpreter {
+	in := &Interpreter{
+		bindings: BuildBaseBindings(),
+		stdout:   os.Stdout,
+		stderr:   os.Stderr,
+	}
+
+	for _, option := range options {
+		option(in)
+	}
+
+	return in
+}
+
+// Evaluates all sexps in code and returns the last value
+func (in *Interpreter) Eval(ctx context.Context, code string) (*Value, error) {
+	return ReadEvalAll(in.runtimeBindings(ctx), code)
+}
+
+// Same as (load path) but with the usual Go error
+func (in *Interpreter) EvalFile(ctx context.Context, path string) (*Value, error) {
+	contents, err := in.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return readEvalFile(in.runtimeBindings(ctx), string(contents))
+}
+
+// Creates or replaces a global
+func (in *Interpreter) Define(name string, value *Value) {
+	in.bindings.DefineSym(name, value)
+}
+
+// Value of a global. Aliases of imported modules work too (see Lookup)
+func (in *Interpreter) Get(name string) (*Value, bool) {
+	return Lookup(in.bindings, BuildSymbol(name))
+}
+
+// Calls the function bound to name with args. Args aren't evaluated, same as
+// with apply
+func (in *Interpreter) Call(ctx context.Context, name string, args ...*Value) (*Value, error) {
+	fn, found := in.Get(name)
+	if !found {
+		return nil, errors.New("Undefined: " + name)
+	}
+
+	bindings := in.runtimeBindings(ctx)
+	if in.compile {
+		if lambda := compileLambda(fn); lambda != nil {
+			return callCompiled(bindings, lambda, args)
+		}
+	}
+
+	return callWithValues(bindings, fn, args)
+}
+
+// An interpreter with the same settings (changed by options, if any) and a
+// copy of the globals. Useful for reloading code without disturbing running
+// evaluations:
+//
+//	next := in.Fork()
+//	if _, err := next.EvalFile(ctx, "router.lisp"); err == nil {
+//		in.ReplaceGlobals(next)
+//	}
+func (in *Interpreter) Fork(options ...Option) *Interpreter {
+	fork := *in
+	fork.bindings = in.bindings.Fork()
+	for _, option := range options {
+		option(&fork)
+	}
+
+	return &fork
+}
+
+// Atomically makes the globals of other (usually a fork) the globals of in
+func (in *Interpreter) ReplaceGlobals(other *Interpreter) {
+	in.bindings.ReplaceGlobals(other.bindings)
+}
+
+// The underlying bindings, for use with the free functions. They carry a new
+// runtime with the interpreter's options (no context), so e.g. load stays
+// within the sandbox. Like any runtime, it mustn't be shared by concurrent
+// evaluations: call Bindings for each of them
+func (in *Interpreter) Bindings() *Bindings {
+	return in.runtimeBindings(context.Background())
+}
+
+func (in *Interpreter) runtimeBindings(ctx context.Context) *Bindings {
+	return in.bindings.WithRuntime(&Runtime{
+		Context:  ctx,
+		Stdout:   in.stdout,
+		Stderr:   in.stderr,
+		ReadFile: in.readFile,
+		MaxSteps: in.limits.MaxSteps,
+		MaxDepth: in.limits.MaxDepth,
+	})
+}
+
+func (in *Interpreter) readFile(path string) ([]byte, error) {
+	resolved, err := in.resolveFile(path)
+	// before reading, so what the hook sees is never newer than what's read
+	if in.fileHook != nil {
+		in.fileHook(resolved)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(resolved)
+}
+
+// Path to read (or the given one if there's nothing to read)
+func (in *Interpreter) resolveFile(path string) (string, error) {
+	if in.sandbox {
+		path = filepath.Clean(path)
+		if filepath.IsAbs(path) || path == ".." || strings.HasPrefix(path, ".."+string(filepath.Separator)) {
+			return path, errors.New("sandbox: can't load " + path)
+		}
+		if len(in.loadPath) == 0 {
+			return path, errors.New("sandbox: no load path to load " + path + " from")
+		}
+	} else if filepath.IsAbs(path) || len(in.loadPath) == 0 {
+		return path, nil
+	}
+
+	var err error
+	for _, dir := range in.loadPath {
+		var info os.FileInfo
+		resolved := filepath.Join(dir, path)
+		info, err = os.Stat(resolved)
+		if err == nil && !info.IsDir() {
+			return resolved, nil
+		}
+		if err == nil {
+			err = errors.New(resolved + " is a directory")
+		}
+	}
+
+	return path, err
+}
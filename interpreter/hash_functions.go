@@ -0,0 +1,159 @@
+package interpreter
+
+import (
+	"errors"
+
+	. "nondv.io/glisp/types"
+)
+
+/*
+ * Hash maps. Argument order follows alist/get and alist/set so they can be
+ * used with ->> the same way.
+ *
+ * hash-set and hash-delete modify the map in place and return it.
+ */
+
+// (hash-get KEY HASH) or (hash-get KEY HASH DEFAULT)
+func nativeHashGet(bindings *Bindings, args *Value) (*Value, error) {
+	length := args.ListLength()
+	if length != 2 && length != 3 {
+		return nil, errors.New("hash-get requires 2 or 3 arguments")
+	}
+
+	key := args.Car()
+	hash := args.Cdr().Car()
+	if !hash.IsHashMap() {
+		return nil, errors.New("hash-get requires a hash map")
+	}
+
+	value, found := hash.ToHashMap().Get(key)
+	if found {
+		return value, nil
+	}
+
+	if length == 3 {
+		return args.Cdr().Cdr().Car(), nil
+	}
+
+	return BuildEmptyList(), nil
+}
+
+// (hash-set KEY VALUE HASH)
+func nativeHashSet(bindings *Bindings, args *Value) (*Value, error) {
+	if args.ListLength() != 3 {
+		return nil, errors.New("hash-set requires 3 arguments")
+	}
+
+	hash := args.Cdr().Cdr().Car()
+	if !hash.IsHashMap() {
+		return nil, errors.New("hash-set requires a hash map")
+	}
+
+	hash.ToHashMap().Set(args.Car(), args.Cdr().Car())
+	return hash, nil
+}
+
+// (hash-delete KEY HASH)
+func nativeHashDelete(bindings *Bindings, args *Value) (*Value, error) {
+	if args.ListLength() != 2 {
+		return nil, errors.New("hash-delete requires 2 arguments")
+	}
+
+	hash := args.Cdr().Car()
+	if !hash.IsHashMap() {
+		return nil, errors.New("hash-delete requires a hash map")
+	}
+
+	hash.ToHashMap().Delete(args.Car())
+	return hash, nil
+}
+
+func nativeHashKeys(bindings *Bindings, args *Value) (*Value, error) {
+	hash, err := hashArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return sliceToList(hash.Keys()), nil
+}
+
+func nativeHashValues(bindings *Bindings, args *Value) (*Value, error) {
+	hash, err := hashArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return sliceToList(hash.Values()), nil
+}
+
+func nativeHashCount(bindings *Bindings, args *Value) (*Value, error) {
+	hash, err := hashArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return BuildInteger(hash.Len()), nil
+}
+
+func nativeHashToAlist(bindings *Bindings, args *Value) (*Value, error) {
+	hash, err := hashArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, values := hash.Keys(), hash.Values()
+	result := BuildEmptyList()
+	for i := len(keys) - 1; i >= 0; i-- {
+		result = BuildCons(BuildCons(keys[i], values[i]), result)
+	}
+
+	return result, nil
+}
+
+// Earlier pairs shadow later ones, same as alist/get
+func nativeAlistToHash(bindings *Bindings, args *Value) (*Value, error) {
+	alist, err := requireOneArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if !alist.IsList() {
+		return nil, errors.New("alist->hash requires a list")
+	}
+
+	result := NewHashMap()
+	for iter := alist; !iter.IsEmptyList(); iter = iter.Cdr() {
+		pair := iter.Car()
+		if !pair.IsCons() {
+			return nil, errors.New("alist->hash requires a list of cons cells")
+		}
+
+		if _, found := result.Get(pair.Car()); !found {
+			result.Set(pair.Car(), pair.Cdr())
+		}
+	}
+
+	return BuildHashMap(result), nil
+}
+
+func hashArg(args *Value) (*HashMap, error) {
+	argument, err := requireOneArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if !argument.IsHashMap() {
+		return nil, errors.New("Not a hash map")
+	}
+
+	return argument.ToHashMap(), nil
+}
+
+func sliceToList(values []*Value) *Value {
+	result := BuildEmptyList()
+	for i := len(values) - 1; i >= 0; i-- {
+		result = BuildCons(values[i], result)
+	}
+
+	return result
+}
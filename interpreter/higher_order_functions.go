@@ -0,0 +1,93 @@
+package interpreter
+
+import (
+	"errors"
+
+	. "nondv.io/glisp/types"
+)
+
+/*
+ * Calling functions on values. Unlike (f x), the arguments are never
+ * evaluated again, so lists and symbols can be passed around as data. Lambdas
+ * with a symbol parameter get the values as their argument list. Special forms
+ * (native fexprs like if or define) would evaluate them, so they're rejected.
+ */
+
+// (apply F ARGS...) - the last argument is a list of the remaining arguments
+func nativeApply(bindings *Bindings, args *Value) (*Value, error) {
+	if !args.IsCons() || !args.Cdr().IsCons() {
+		return nil, errors.New("apply requires a function and a list of arguments")
+	}
+
+	var values []*Value
+	iter := args.Cdr()
+	for ; iter.Cdr().IsCons(); iter = iter.Cdr() {
+		values = append(values, iter.Car())
+	}
+
+	last := iter.Car()
+	if !last.IsList() {
+		return nil, errors.New("apply: last argument must be a list")
+	}
+	for item := last; !item.IsEmptyList(); item = item.Cdr() {
+		values = append(values, item.Car())
+	}
+
+	return callWithValues(bindings, args.Car(), values)
+}
+
+// (funcall F ARGS...)
+func nativeFuncall(bindings *Bindings, args *Value) (*Value, error) {
+	if !args.IsCons() {
+		return nil, errors.New("funcall requires a function")
+	}
+
+	return callWithValues(bindings, args.Car(), listToSlice(args.Cdr()))
+}
+
+func nativeIdentity(bindings *Bindings, args *Value) (*Value, error) {
+	return requireOneArg(args)
+}
+
+// (compose F G H) - a function calling H first and F last
+func nativeCompose(bindings *Bindings, args *Value) (*Value, error) {
+	fns := listToSlice(args)
+
+	return BuildApplicativeFn(func(bindings *Bindings, args *Value) (*Value, error) {
+		if len(fns) == 0 {
+			return requireOneArg(args)
+		}
+
+		result, err := callWithValues(bindings, fns[len(fns)-1], listToSlice(args))
+		for i := len(fns) - 2; i >= 0 && err == nil; i-- {
+			result, err = callWithValues(bindings, fns[i], []*Value{result})
+		}
+
+		return result, err
+	}), nil
+}
+
+// (partial F ARGS...) - a function calling F with ARGS followed by its own
+// arguments
+func nativePartial(bindings *Bindings, args *Value) (*Value, error) {
+	if !args.IsCons() {
+		return nil, errors.New("partial requires a function")
+	}
+
+	fn := args.Car()
+	fixed := listToSlice(args.Cdr())
+
+	return BuildApplicativeFn(func(bindings *Bindings, args *Value) (*Value, error) {
+		values := append(append([]*Value{}, fixed...), listToSlice(args)...)
+		return callWithValues(bindings, fn, values)
+	}), nil
+}
+
+func listToSlice(list *Value) []*Value {
+	var result []*Value
+	for iter := list; !iter.IsEmptyList(); iter = iter.Cdr() {
+		result = append(result, iter.Car())
+	}
+
+	return result
+}
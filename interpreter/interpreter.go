@@ -9,20 +9,103 @@ import (
 	. "nondv.io/glisp/types"
 )
 
+// Special forms are shared so compiled code can recognise them
+var (
+	ifFn  = BuildNativeFn(nativeIf)
+	letFn = BuildNativeFn(nativeLet)
+)
+
+// Calls and argument lists must be proper lists: (f 1 . 2) is an error
+var errImproperArgs = errors.New("improper argument list")
+
+// Returns the builtin native for "if" or "let", nil for anything else
+func SpecialForm(name string) *Value {
+	switch name {
+	case "if":
+		return ifFn
+	case "let":
+		return letFn
+	}
+
+	return nil
+}
+
 func BuildBaseBindings() *Bindings {
-	result := &Bindings{"nil", BuildEmptyList(), nil}
-	// result = result.Assoc(BuildSymbol("t"), BuildSymbol("t"))
-	result = result.Assoc(BuildSymbol("eval"), BuildNativeFn(nativeEval))
-	result = result.Assoc(BuildSymbol("let"), BuildNativeFn(nativeLet))
-	result = result.Assoc(BuildSymbol("define"), BuildNativeFn(nativeDefine))
-	result = result.Assoc(BuildSymbol("if"), BuildNativeFn(nativeIf))
-	result = result.Assoc(BuildSymbol("load"), BuildNativeFn(nativeLoad))
-	result = result.Assoc(BuildSymbol("="), BuildNativeFn(nativeEqual))
-	result = result.Assoc(BuildSymbol("+"), BuildNativeFn(nativePlus))
-	result = result.Assoc(BuildSymbol("car"), BuildNativeFn(nativeCar))
-	result = result.Assoc(BuildSymbol("cdr"), BuildNativeFn(nativeCdr))
-	result = result.Assoc(BuildSymbol("cons"), BuildNativeFn(nativeCons))
-	result = result.Assoc(BuildSymbol("print"), BuildNativeFn(nativePrint))
+	result := NewBindings()
+	result.Define(BuildSymbol("nil"), BuildEmptyList())
+	result.Define(BuildSymbol("t"), BuildBool(true))
+	result.Define(BuildSymbol("false"), BuildBool(false))
+	result.Define(BuildSymbol("eval"), BuildApplicativeFn(nativeEval))
+	result.Define(BuildSymbol("let"), letFn)
+	result.Define(BuildSymbol("define"), BuildNativeFn(nativeDefine))
+	result.Define(BuildSymbol("set!"), BuildNativeFn(nativeSet))
+	result.Define(BuildSymbol("if"), ifFn)
+	result.Define(BuildSymbol("load"), BuildNativeFn(nativeLoad))
+	result.Define(BuildSymbol("module"), BuildNativeFn(nativeModule))
+	result.Define(BuildSymbol("require"), BuildApplicativeFn(nativeRequire))
+	result.Define(BuildSymbol("import"), BuildNativeFn(nativeImport))
+	result.Define(BuildSymbol("match"), BuildNativeFn(nativeMatch))
+	result.Define(BuildSymbol("="), BuildApplicativeFn(nativeEqual))
+	result.Define(BuildSymbol("+"), BuildApplicativeFn(nativePlus))
+	result.Define(BuildSymbol("car"), BuildApplicativeFn(nativeCar))
+	result.Define(BuildSymbol("cdr"), BuildApplicativeFn(nativeCdr))
+	result.Define(BuildSymbol("cons"), BuildApplicativeFn(nativeCons))
+	result.Define(BuildSymbol("print"), BuildApplicativeFn(nativePrint))
+	result.Define(BuildSymbol("eprint"), BuildApplicativeFn(nativeEprint))
+	result.Define(BuildSymbol("apply"), BuildApplicativeFn(nativeApply))
+	result.Define(BuildSymbol("funcall"), BuildApplicativeFn(nativeFuncall))
+	result.Define(BuildSymbol("identity"), BuildApplicativeFn(nativeIdentity))
+	result.Define(BuildSymbol("compose"), BuildApplicativeFn(nativeCompose))
+	result.Define(BuildSymbol("partial"), BuildApplicativeFn(nativePartial))
+	result.Define(BuildSymbol("spawn"), BuildNativeFn(nativeSpawn))
+	result.Define(BuildSymbol("await"), BuildApplicativeFn(nativeAwait))
+	result.Define(BuildSymbol("make-chan"), BuildApplicativeFn(nativeMakeChan))
+	result.Define(BuildSymbol("chan-send"), BuildApplicativeFn(nativeChanSend))
+	result.Define(BuildSymbol("chan-recv"), BuildApplicativeFn(nativeChanRecv))
+	result.Define(BuildSymbol("chan-close"), BuildApplicativeFn(nativeChanClose))
+	result.Define(BuildSymbol("select"), BuildNativeFn(nativeSelect))
+	result.Define(BuildSymbol("atom"), BuildApplicativeFn(nativeAtom))
+	result.Define(BuildSymbol("deref"), BuildApplicativeFn(nativeDeref))
+	result.Define(BuildSymbol("reset!"), BuildApplicativeFn(nativeReset))
+	result.Define(BuildSymbol("swap!"), BuildApplicativeFn(nativeSwap))
+	result.Define(BuildSymbol("compare-and-set!"), BuildApplicativeFn(nativeCompareAndSet))
+	result.Define(BuildSymbol("mutex"), BuildApplicativeFn(nativeMutex))
+	result.Define(BuildSymbol("with-lock"), BuildNativeFn(nativeWithLock))
+	result.Define(BuildSymbol("ref"), BuildApplicativeFn(nativeRef))
+	result.Define(BuildSymbol("dosync"), BuildNativeFn(nativeDosync))
+	result.Define(BuildSymbol("ref-set"), BuildApplicativeFn(nativeRefSet))
+	result.Define(BuildSymbol("alter"), BuildApplicativeFn(nativeAlter))
+	result.Define(BuildSymbol("keyword->string"), BuildApplicativeFn(nativeKeywordToString))
+	result.Define(BuildSymbol("string->keyword"), BuildApplicativeFn(nativeStringToKeyword))
+	result.Define(BuildSymbol("hash-get"), BuildApplicativeFn(nativeHashGet))
+	result.Define(BuildSymbol("hash-set"), BuildApplicativeFn(nativeHashSet))
+	result.Define(BuildSymbol("hash-delete"), BuildApplicativeFn(nativeHashDelete))
+	result.Define(BuildSymbol("hash-keys"), BuildApplicativeFn(nativeHashKeys))
+	result.Define(BuildSymbol("hash-values"), BuildApplicativeFn(nativeHashValues))
+	result.Define(BuildSymbol("hash-count"), BuildApplicativeFn(nativeHashCount))
+	result.Define(BuildSymbol("hash->alist"), BuildApplicativeFn(nativeHashToAlist))
+	result.Define(BuildSymbol("alist->hash"), BuildApplicativeFn(nativeAlistToHash))
+	result.Define(BuildSymbol("vector"), BuildApplicativeFn(nativeVector))
+	result.Define(BuildSymbol("vector-ref"), BuildApplicativeFn(nativeVectorRef))
+	result.Define(BuildSymbol("vector-set!"), BuildApplicativeFn(nativeVectorSet))
+	result.Define(BuildSymbol("vector-length"), BuildApplicativeFn(nativeVectorLength))
+	result.Define(BuildSymbol("vector-push"), BuildApplicativeFn(nativeVectorPush))
+	result.Define(BuildSymbol("subvector"), BuildApplicativeFn(nativeSubvector))
+	result.Define(BuildSymbol("list->vector"), BuildApplicativeFn(nativeListToVector))
+	result.Define(BuildSymbol("vector->list"), BuildApplicativeFn(nativeVectorToList))
+	result.Define(BuildSymbol("pvector"), BuildApplicativeFn(nativePVector))
+	result.Define(BuildSymbol("pvector-ref"), BuildApplicativeFn(nativePVectorRef))
+	result.Define(BuildSymbol("pvector-assoc"), BuildApplicativeFn(nativePVectorAssoc))
+	result.Define(BuildSymbol("pvector-conj"), BuildApplicativeFn(nativePVectorConj))
+	result.Define(BuildSymbol("pvector-length"), BuildApplicativeFn(nativePVectorLength))
+	result.Define(BuildSymbol("pvector->list"), BuildApplicativeFn(nativePVectorToList))
+	result.Define(BuildSymbol("pmap"), BuildApplicativeFn(nativePMap))
+	result.Define(BuildSymbol("pmap-get"), BuildApplicativeFn(nativePMapGet))
+	result.Define(BuildSymbol("pmap-assoc"), BuildApplicativeFn(nativePMapAssoc))
+	result.Define(BuildSymbol("pmap-dissoc"), BuildApplicativeFn(nativePMapDissoc))
+	result.Define(BuildSymbol("pmap-count"), BuildApplicativeFn(nativePMapCount))
+	result.Define(BuildSymbol("pmap-keys"), BuildApplicativeFn(nativePMapKeys))
+	result.Define(BuildSymbol("pmap-values"), BuildApplicativeFn(nativePMapValues))
 
 	return result
 }
@@ -77,12 +160,19 @@ func ReadEvalAll(bindings *Bindings, txt string) (*Value, error) {
 }
 
 func Eval(bindings *Bindings, v *Value) (*Value, error) {
-	if v.IsInteger() || v.IsEmptyList() || v.IsString() {
+	if rt := bindings.Runtime(); rt != nil {
+		defer rt.Leave()
+		if err := rt.Enter(); err != nil {
+			return nil, err
+		}
+	}
+
+	if v.IsSelfEvaluating() {
 		return v, nil
 	}
 
 	if v.IsSymbol() {
-		val, found := bindings.Lookup(v)
+		val, found := Lookup(bindings, v)
 		if !found {
 			return nil, errors.New("Undefined")
 		}
@@ -93,15 +183,90 @@ func Eval(bindings *Bindings, v *Value) (*Value, error) {
 		return nil, errors.New("Not eval-able")
 	}
 
+	// hash map literals evaluate their keys and values into a fresh map
+	if v.IsHashMap() {
+		// every key and value is evaluated, even if the forms are equal
+		keys, values := v.ToHashMap().LiteralEntries()
+		result := NewHashMap()
+		for i := range keys {
+			key, err := Eval(bindings, keys[i])
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := Eval(bindings, values[i])
+			if err != nil {
+				return nil, err
+			}
+
+			result.Set(key, value)
+		}
+
+		return BuildHashMap(result), nil
+	}
+
+	// same for vectors
+	if v.IsVector() {
+		literal := v.ToVector()
+		items := make([]*Value, literal.Len())
+		for i, item := range literal.Items {
+			value, err := Eval(bindings, item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = value
+		}
+
+		return BuildVector(items), nil
+	}
+
+	if v.IsPVector() {
+		literal := v.ToPVector()
+		result := EmptyPVector()
+		for i := 0; i < literal.Len(); i++ {
+			value, err := Eval(bindings, literal.Get(i))
+			if err != nil {
+				return nil, err
+			}
+			result = result.Conj(value)
+		}
+
+		return BuildPVector(result), nil
+	}
+
+	if v.IsPMap() {
+		keys, values := v.ToPMap().LiteralEntries()
+		result := EmptyPMap()
+		for i := range keys {
+			key, err := Eval(bindings, keys[i])
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := Eval(bindings, values[i])
+			if err != nil {
+				return nil, err
+			}
+
+			result = result.Assoc(key, value)
+		}
+
+		return BuildPMap(result), nil
+	}
+
 	if v.IsList() {
 		fn := v.Car()
-		if fn.IsSymbol() && fn.SymbolName() == "lambda" {
+		if fn.IsLambdaSymbol() {
 			return v, nil
 		}
 
 		return callFn(bindings, fn, v.Cdr())
 	}
 
+	if v.IsCons() {
+		return nil, errImproperArgs
+	}
+
 	panic("Unexpected eval argument")
 }
 
@@ -115,52 +280,115 @@ func callFn(bindings *Bindings, fn *Value, args *Value) (*Value, error) {
 		return nil, err
 	}
 
+	return Call(bindings, fn, args)
+}
+
+// Same as evaluating (fn args...) but fn is already evaluated. args are
+// still forms
+func Call(bindings *Bindings, fn *Value, args *Value) (*Value, error) {
+	var err error
 	if fn.IsNativeFn() {
-		resPointer, err := fn.NativeFn()(bindings, args)
-		return resPointer, err
+		native := fn.ToNativeFn()
+		if native.EvalArgs {
+			args, err = evalArgs(bindings, args)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return native.Fn(bindings, args)
 	}
 
 	if fn.IsList() && fn.Car().IsLambdaSymbol() {
 		parameter := fn.Cdr().Car()
-		if !parameter.IsSymbol() && !parameter.IsList() {
+		if !parameter.IsSymbol() && !parameter.IsList() && !parameter.IsCons() {
 			return nil, errors.New("format: (lambda SYMBOL-OR-LIST BODY)")
 		}
 		var lambdaBindings *Bindings
 		if parameter.IsSymbol() {
 			lambdaBindings = bindings.Assoc(parameter, args)
 		} else {
-			if parameter.ListLength() != args.ListLength() {
-				return nil, errors.New("too many/not enough arguments")
-			}
-
-			lambdaBindings = bindings
-			for iter := parameter; !iter.IsEmptyList(); {
-				varSym := iter.Car()
-				if !varSym.IsSymbol() {
-					return nil, errors.New("parameter is not a symbol")
-				}
-
-				argN, err := Eval(bindings, args.Car())
-				if err != nil {
-					return nil, err
-				}
-
-				lambdaBindings = lambdaBindings.Assoc(varSym, argN)
-				iter = iter.Cdr()
-				args = args.Cdr()
-			}
-		}
-		res := BuildEmptyList()
-		body := fn.Cdr().Cdr()
-		for !body.IsEmptyList() {
-			res, err = Eval(lambdaBindings, body.Car())
+			lambdaBindings, err = bindArgs(bindings, parameter, args)
 			if err != nil {
 				return nil, err
 			}
-			body = body.Cdr()
 		}
-		return res, nil
+		return evalBody(lambdaBindings, fn.Cdr().Cdr())
+	}
+
+	return nil, errors.New("Not a function")
+}
+
+// Calls fn with arguments that are already evaluated. Natives and lambdas
+// with parameter lists get the values, lambdas with a symbol parameter get
+// them as their argument list. Native fexprs (special forms) would evaluate
+// them again, so they can't be called this way
+func callWithValues(bindings *Bindings, fn *Value, values []*Value) (*Value, error) {
+	if fn.IsNativeFn() {
+		if !fn.ToNativeFn().EvalArgs {
+			return nil, errors.New("Special forms can't be called on values")
+		}
+		return fn.ToNativeFn().Fn(bindings, sliceToList(values))
+	}
+
+	if fn.IsList() && fn.Car().IsLambdaSymbol() {
+		parameter := fn.Cdr().Car()
+		if parameter.IsSymbol() {
+			return evalBody(bindings.Assoc(parameter, sliceToList(values)), fn.Cdr().Cdr())
+		}
+
+		if !parameter.IsList() && !parameter.IsCons() {
+			return nil, errors.New("format: (lambda SYMBOL-OR-LIST BODY)")
+		}
+
+		list, err := parseLambdaList(parameter)
+		if err != nil {
+			return nil, err
+		}
+		lambdaBindings, err := bindParams(bindings, list, values)
+		if err != nil {
+			return nil, err
+		}
+		return evalBody(lambdaBindings, fn.Cdr().Cdr())
 	}
 
 	return nil, errors.New("Not a function")
 }
+
+// Evaluates forms in order and returns the last result, () if there are none
+func evalBody(bindings *Bindings, body *Value) (*Value, error) {
+	result := BuildEmptyList()
+	for iter := body; !iter.IsEmptyList(); iter = iter.Cdr() {
+		var err error
+		result, err = Eval(bindings, iter.Car())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// Evaluates argument forms and binds them to the lambda list
+func bindArgs(bindings *Bindings, params *Value, args *Value) (*Bindings, error) {
+	list, err := parseLambdaList(params)
+	if err != nil {
+		return nil, err
+	}
+
+	// arity is checked before evaluating anything
+	if list.isSimple() && len(list.required) != args.ListLength() {
+		return nil, errors.New("too many/not enough arguments")
+	}
+
+	var values []*Value
+	for iter := args; !iter.IsEmptyList(); iter = iter.Cdr() {
+		value, err := Eval(bindings, iter.Car())
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return bindParams(bindings, list, values)
+}
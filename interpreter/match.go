@@ -0,0 +1,172 @@
+package interpreter
+
+import (
+	"errors"
+
+	. "nondv.io/glisp/types"
+)
+
+/*
+ * (match EXPR
+ *   (PATTERN BODY...)
+ *   (PATTERN :when GUARD BODY...)
+ *   ...)
+ *
+ * Evaluates EXPR and runs the body of the first clause whose pattern matches
+ * (and whose guard is true) with the pattern variables bound. Patterns:
+ *
+ *   1 "str" #t :kw ()   - literals, compared with =
+ *   x                   - binds anything to x
+ *   _                   - matches anything without binding
+ *   (quote sym)         - the symbol sym itself
+ *   (p1 p2) (p1 . rest) - lists, element by element
+ *   (? pred) (? pred x) - values for which (pred value) is true, optionally
+ *                         bound to x
+ *   {"key" p ...}       - hash maps, persistent maps and alists, like in
+ *                         destructuring
+ */
+
+var (
+	wildcardSymbol  = BuildSymbol("_")
+	quoteSymbol     = BuildSymbol("quote")
+	predicateSymbol = BuildSymbol("?")
+	whenKeyword     = BuildKeyword("when")
+)
+
+func nativeMatch(bindings *Bindings, args *Value) (*Value, error) {
+	if !args.IsCons() {
+		return nil, errors.New("syntax: (match EXPR (PATTERN BODY...)...)")
+	}
+
+	value, err := Eval(bindings, args.Car())
+	if err != nil {
+		return nil, err
+	}
+
+	for iter := args.Cdr(); !iter.IsEmptyList(); iter = iter.Cdr() {
+		clause := iter.Car()
+		if !clause.IsCons() || !clause.IsList() {
+			return nil, errors.New("match: clause must be (PATTERN BODY...)")
+		}
+
+		matched, syms, vals, err := matchPattern(bindings, clause.Car(), value, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			continue
+		}
+
+		clauseBindings := bindings.Extend(syms, vals)
+		body := clause.Cdr()
+		if body.IsCons() && body.Car() == whenKeyword {
+			if !body.Cdr().IsCons() {
+				return nil, errors.New("match: :when requires a guard")
+			}
+
+			guard, err := Eval(clauseBindings, body.Cdr().Car())
+			if err != nil {
+				return nil, err
+			}
+			if !guard.IsTruthy() {
+				continue
+			}
+			body = body.Cdr().Cdr()
+		}
+
+		return evalBody(clauseBindings, body)
+	}
+
+	return nil, errors.New("match: no clause matched " + value.PrintStr())
+}
+
+func matchPattern(bindings *Bindings, pattern *Value, value *Value, syms []*Value, vals []*Value) (bool, []*Value, []*Value, error) {
+	if pattern == wildcardSymbol {
+		return true, syms, vals, nil
+	}
+
+	if pattern.IsSymbol() {
+		return true, append(syms, pattern), append(vals, value), nil
+	}
+
+	if pattern.IsInteger() || pattern.IsString() || pattern.IsBool() || pattern.IsKeyword() || pattern.IsEmptyList() {
+		return Equal(pattern, value), syms, vals, nil
+	}
+
+	if pattern.IsCons() && pattern.Car() == quoteSymbol {
+		if !pattern.IsList() || pattern.ListLength() != 2 {
+			return false, nil, nil, errors.New("match: invalid pattern " + pattern.PrintStr())
+		}
+		return Equal(pattern.Cdr().Car(), value), syms, vals, nil
+	}
+
+	if pattern.IsCons() && pattern.Car() == predicateSymbol {
+		return matchPredicate(bindings, pattern, value, syms, vals)
+	}
+
+	if pattern.IsCons() {
+		if !value.IsCons() {
+			return false, nil, nil, nil
+		}
+
+		matched, syms, vals, err := matchPattern(bindings, pattern.Car(), value.Car(), syms, vals)
+		if !matched || err != nil {
+			return false, nil, nil, err
+		}
+		return matchPattern(bindings, pattern.Cdr(), value.Cdr(), syms, vals)
+	}
+
+	if pattern.IsHashMap() {
+		keys, subpatterns := pattern.ToHashMap().Keys(), pattern.ToHashMap().Values()
+		for i, key := range keys {
+			found, ok := lookupKey(value, key)
+			if !ok {
+				return false, nil, nil, nil
+			}
+
+			var matched bool
+			var err error
+			matched, syms, vals, err = matchPattern(bindings, subpatterns[i], found, syms, vals)
+			if !matched || err != nil {
+				return false, nil, nil, err
+			}
+		}
+		return true, syms, vals, nil
+	}
+
+	return false, nil, nil, errors.New("match: invalid pattern " + pattern.PrintStr())
+}
+
+// (? PRED) or (? PRED SYMBOL)
+func matchPredicate(bindings *Bindings, pattern *Value, value *Value, syms []*Value, vals []*Value) (bool, []*Value, []*Value, error) {
+	length := 0
+	if pattern.IsList() {
+		length = pattern.ListLength()
+	}
+	if length != 2 && length != 3 {
+		return false, nil, nil, errors.New("match: predicate pattern must be (? PRED) or (? PRED SYMBOL)")
+	}
+
+	pred, err := Eval(bindings, pattern.Cdr().Car())
+	if err != nil {
+		return false, nil, nil, err
+	}
+
+	result, err := callWithValues(bindings, pred, []*Value{value})
+	if err != nil {
+		return false, nil, nil, err
+	}
+	if !result.IsTruthy() {
+		return false, nil, nil, nil
+	}
+
+	if length == 3 {
+		sym := pattern.Cdr().Cdr().Car()
+		if !sym.IsSymbol() {
+			return false, nil, nil, errors.New("match: predicate pattern must be (? PRED) or (? PRED SYMBOL)")
+		}
+		return true, append(syms, sym), append(vals, value), nil
+	}
+
+	return true, syms, vals, nil
+}
@@ -0,0 +1,501 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"nondv.io/glisp/reader"
+	. "nondv.io/glisp/types"
+)
+
+/*
+ * Modules:
+ *
+ *   (module NAME (export SYMBOL...) BODY...)
+ *   (require PATH)            - evaluates the file once, returns what it returned
+ *   (import NAME)             - requires NAME.lisp unless module NAME is loaded
+ *   (import NAME :as ALIAS)   - same, and ALIAS/SYMBOL refers to NAME/SYMBOL
+ *
+ * A module is usually a file starting with (module NAME (export ...)): the
+ * rest of the file is its body. Top-level definitions in the body become
+ * globals named NAME/SYMBOL. Since there are no closures, references to them
+ * (and to ALIAS/SYMBOL for modules imported in the body) are rewritten before
+ * evaluation, everywhere in the body except quoted data and places where a
+ * lambda parameter or a let variable of the same name shadows them. Without
+ * an export clause everything is exported.
+ *
+ * Aliases imported inside a module are private to it, aliases imported
+ * anywhere else are global. Only exported symbols can be referred to through
+ * an alias but NAME/SYMBOL is an ordinary global and is always accessible.
+ *
+ * Loaded files and modules are remembered alongside the globals (see
+ * Bindings.Host), so forks share them while reload (which starts from
+ * scratch) loads everything again. Images don't include them: importing a
+ * module in a restored image loads its file again. Circular imports are
+ * errors, reported as the chain of files being loaded.
+ */
+
+var (
+	moduleSymbol = BuildSymbol("module")
+	exportSymbol = BuildSymbol("export")
+	importSymbol = BuildSymbol("import")
+	defineSymbol = BuildSymbol("define")
+	letSymbol    = BuildSymbol("let")
+	asKeyword    = BuildKeyword("as")
+
+	// Bound (dynamically) to the list of files being loaded
+	loadingSymbol = BuildSymbol("__LOADING")
+)
+
+// What the module system remembers, kept as the host state of the bindings.
+// Never modified (forks share it): changes build a new one
+type modules struct {
+	// exported symbols (unqualified) of loaded modules
+	exports map[string][]*Value
+	// global aliases and the modules they refer to
+	aliases map[string]string
+	// ALIAS/SYMBOL to NAME/SYMBOL for every symbol exported through an alias,
+	// so looking them up doesn't have to parse symbol names
+	resolved map[*Value]*Value
+	// results of required files
+	required map[string]*Value
+}
+
+func modulesOf(bindings *Bindings) *modules {
+	if m, ok := bindings.Host().(*modules); ok {
+		return m
+	}
+
+	return &modules{}
+}
+
+// Replaces the modules of bindings with a changed copy
+func updateModules(bindings *Bindings, change func(m *modules)) {
+	bindings.UpdateHost(func(host any) any {
+		m, ok := host.(*modules)
+		if !ok {
+			m = &modules{}
+		}
+
+		m = &modules{copyMap(m.exports), copyMap(m.aliases), nil, copyMap(m.required)}
+		change(m)
+		m.resolved = make(map[*Value]*Value)
+		for alias, module := range m.aliases {
+			for _, export := range m.exports[module] {
+				m.resolved[qualify(alias, export)] = qualify(module, export)
+			}
+		}
+		return m
+	})
+}
+
+func copyMap[K comparable, V any](m map[K]V) map[K]V {
+	result := make(map[K]V, len(m)+1)
+	maps.Copy(result, m)
+	return result
+}
+
+func (m *modules) isExported(module string, sym *Value) bool {
+	return slices.Contains(m.exports[module], sym)
+}
+
+func qualify(module string, sym *Value) *Value {
+	return BuildSymbol(module + "/" + sym.SymbolName())
+}
+
+// Bindings.Lookup that also resolves ALIAS/SYMBOL for modules imported with
+// (import NAME :as ALIAS)
+func Lookup(bindings *Bindings, sym *Value) (*Value, bool) {
+	if val, found := bindings.Lookup(sym); found {
+		return val, true
+	}
+
+	qualified, found := modulesOf(bindings).resolved[sym]
+	if !found {
+		return nil, false
+	}
+
+	return bindings.Lookup(qualified)
+}
+
+// Evaluates the contents of a file. If its first form is (module ...), the
+// rest of the file is the module's body
+func readEvalFile(bindings *Bindings, code string) (*Value, error) {
+	forms, err := reader.ReadAll(code)
+	if err != nil {
+		return nil, err
+	}
+
+	if forms.IsCons() && forms.Car().IsCons() && forms.Car().Car() == moduleSymbol {
+		module := sliceToList(append(listToSlice(forms.Car()), listToSlice(forms.Cdr())...))
+		forms = BuildCons(module, BuildEmptyList())
+	}
+
+	return evalBody(bindings, forms)
+}
+
+// Adds path to the list of files being loaded, failing if it's there already
+func startLoading(bindings *Bindings, path string) (*Bindings, error) {
+	var entries []*Value
+	if loading, found := bindings.Lookup(loadingSymbol); found {
+		entries = listToSlice(loading)
+	}
+
+	for i, existing := range entries {
+		if existing.ToStr() == path {
+			var chain []string
+			for _, e := range entries[i:] {
+				chain = append(chain, e.ToStr())
+			}
+			return nil, errors.New("circular import: " + strings.Join(append(chain, path), " -> "))
+		}
+	}
+
+	return bindings.Assoc(loadingSymbol, sliceToList(append(entries, BuildString(path)))), nil
+}
+
+func nativeRequire(bindings *Bindings, args *Value) (*Value, error) {
+	path, err := requireOneArg(args)
+	if err != nil {
+		return nil, err
+	}
+	if !path.IsString() {
+		return nil, errors.New("require: path must be a string")
+	}
+
+	return requireFile(bindings, path.ToStr())
+}
+
+func requireFile(bindings *Bindings, path string) (*Value, error) {
+	if result, found := modulesOf(bindings).required[path]; found {
+		return result, nil
+	}
+
+	bindings, err := startLoading(bindings, path)
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := fileReader(bindings)(path)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := readEvalFile(bindings, string(contents))
+	if err != nil {
+		return nil, err
+	}
+
+	updateModules(bindings, func(m *modules) { m.required[path] = result })
+	return result, nil
+}
+
+func nativeImport(bindings *Bindings, args *Value) (*Value, error) {
+	module, alias, err := importModule(bindings, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if alias != module {
+		updateModules(bindings, func(m *modules) { m.aliases[alias.SymbolName()] = module.SymbolName() })
+	}
+
+	return module, nil
+}
+
+// Loads the module unless it's loaded already. Returns its name and the alias
+// (the name itself if there isn't one)
+func importModule(bindings *Bindings, args *Value) (module *Value, alias *Value, err error) {
+	length := args.ListLength()
+	if length != 1 && length != 3 || !args.Car().IsSymbol() {
+		return nil, nil, errors.New("syntax: (import NAME [:as ALIAS])")
+	}
+
+	module, alias = args.Car(), args.Car()
+	if length == 3 {
+		if args.Cdr().Car() != asKeyword || !args.Cdr().Cdr().Car().IsSymbol() {
+			return nil, nil, errors.New("syntax: (import NAME [:as ALIAS])")
+		}
+		alias = args.Cdr().Cdr().Car()
+	}
+
+	name := module.SymbolName()
+	if _, loaded := modulesOf(bindings).exports[name]; loaded {
+		return module, alias, nil
+	}
+
+	// a module importing a module that imports it fails here: NAME.lisp is
+	// being loaded already
+	if _, err := requireFile(bindings, name+".lisp"); err != nil {
+		return nil, nil, err
+	}
+	if _, loaded := modulesOf(bindings).exports[name]; !loaded {
+		return nil, nil, errors.New("import: " + name + ".lisp doesn't define module " + name)
+	}
+
+	return module, alias, nil
+}
+
+// Names defined and aliases imported by the module being loaded
+type moduleScope struct {
+	bindings *Bindings
+	name     string
+	defined  map[*Value]bool
+	aliases  map[string]string
+}
+
+func nativeModule(bindings *Bindings, args *Value) (*Value, error) {
+	syntaxError := errors.New("syntax: (module NAME [(export SYMBOL...)] BODY...)")
+	if !args.IsCons() || !args.IsList() || !args.Car().IsSymbol() || strings.Contains(args.Car().SymbolName(), "/") {
+		return nil, syntaxError
+	}
+
+	name, body := args.Car(), args.Cdr()
+
+	var exports []*Value
+	exportAll := true
+	if body.IsCons() && body.Car().IsCons() && body.Car().Car() == exportSymbol {
+		if !body.Car().IsList() {
+			return nil, syntaxError
+		}
+		exports, exportAll = listToSlice(body.Car().Cdr()), false
+		body = body.Cdr()
+	}
+
+	scope := &moduleScope{bindings, name.SymbolName(), make(map[*Value]bool), make(map[string]string)}
+	for iter := body; !iter.IsEmptyList(); iter = iter.Cdr() {
+		form := iter.Car()
+		if form.IsCons() && form.Car() == defineSymbol && form.Cdr().IsCons() && form.Cdr().Car().IsSymbol() {
+			sym := form.Cdr().Car()
+			if exportAll && !scope.defined[sym] {
+				exports = append(exports, sym)
+			}
+			scope.defined[sym] = true
+		}
+	}
+
+	for _, export := range exports {
+		if !scope.defined[export] {
+			return nil, errors.New("module " + scope.name + ": exported " + export.PrintStr() + " isn't defined")
+		}
+	}
+
+	for iter := body; !iter.IsEmptyList(); iter = iter.Cdr() {
+		form := iter.Car()
+		if form.IsCons() && form.Car() == importSymbol {
+			module, alias, err := importModule(bindings, form.Cdr())
+			if err != nil {
+				return nil, err
+			}
+			scope.aliases[alias.SymbolName()] = module.SymbolName()
+			continue
+		}
+
+		form, err := scope.rewrite(form, nil)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := Eval(bindings, form); err != nil {
+			return nil, err
+		}
+	}
+
+	updateModules(bindings, func(m *modules) { m.exports[scope.name] = exports })
+	return name, nil
+}
+
+// Qualifies symbols defined in the module and resolves imported aliases.
+// Symbols in local are bound by an enclosing lambda or let and stay as they are
+func (s *moduleScope) rewrite(v *Value, local map[*Value]bool) (*Value, error) {
+	switch {
+	case v.IsSymbol():
+		if local[v] {
+			return v, nil
+		}
+		return s.rewriteSymbol(v)
+
+	case v.IsCons():
+		head := v.Car()
+		if head.IsSymbol() && !s.defined[head] && !local[head] {
+			switch {
+			case head == quoteSymbol:
+				return v, nil
+			case head.IsLambdaSymbol() && v.Cdr().IsCons():
+				return s.rewriteLambda(v, local)
+			case head == letSymbol && v.Cdr().IsCons() && v.Cdr().Car().IsList():
+				return s.rewriteLet(v, local)
+			}
+		}
+
+		car, err := s.rewrite(v.Car(), local)
+		if err != nil {
+			return nil, err
+		}
+		cdr, err := s.rewrite(v.Cdr(), local)
+		if err != nil {
+			return nil, err
+		}
+		return BuildCons(car, cdr), nil
+
+	case v.IsVector():
+		items, err := s.rewriteAll(v.ToVector().Items, local)
+		if err != nil {
+			return nil, err
+		}
+		return BuildVector(items), nil
+
+	case v.IsHashMap():
+		literalKeys, literalValues := v.ToHashMap().LiteralEntries()
+		keys, err := s.rewriteAll(literalKeys, local)
+		if err != nil {
+			return nil, err
+		}
+		values, err := s.rewriteAll(literalValues, local)
+		if err != nil {
+			return nil, err
+		}
+		return BuildHashMap(NewHashMapLiteral(keys, values)), nil
+
+	case v.IsPVector():
+		result := EmptyPVector()
+		for i := 0; i < v.ToPVector().Len(); i++ {
+			item, err := s.rewrite(v.ToPVector().Get(i), local)
+			if err != nil {
+				return nil, err
+			}
+			result = result.Conj(item)
+		}
+		return BuildPVector(result), nil
+
+	case v.IsPMap():
+		literalKeys, literalValues := v.ToPMap().LiteralEntries()
+		keys, err := s.rewriteAll(literalKeys, local)
+		if err != nil {
+			return nil, err
+		}
+		values, err := s.rewriteAll(literalValues, local)
+		if err != nil {
+			return nil, err
+		}
+		return BuildPMap(NewPMapLiteral(keys, values)), nil
+	}
+
+	return v, nil
+}
+
+func (s *moduleScope) rewriteAll(values []*Value, local map[*Value]bool) ([]*Value, error) {
+	result := make([]*Value, len(values))
+	for i, v := range values {
+		var err error
+		if result[i], err = s.rewrite(v, local); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// (lambda PARAMS BODY...): only defaults of &optional and &key parameters
+// are rewritten in PARAMS, the parameters are local in them and in BODY
+func (s *moduleScope) rewriteLambda(form *Value, local map[*Value]bool) (*Value, error) {
+	params := form.Cdr().Car()
+	local = withLocals(local, lambdaListSymbols(params))
+
+	var items []*Value
+	var section *Value
+	iter := params
+	for ; iter.IsCons(); iter = iter.Cdr() {
+		param := iter.Car()
+		switch {
+		case param == optionalMarker || param == restMarker || param == keyMarker:
+			section = param
+		case (section == optionalMarker || section == keyMarker) && param.IsCons() && param.Cdr().IsCons():
+			init, err := s.rewrite(param.Cdr().Car(), local)
+			if err != nil {
+				return nil, err
+			}
+			param = BuildCons(param.Car(), BuildCons(init, param.Cdr().Cdr()))
+		}
+		items = append(items, param)
+	}
+
+	// keeps a dotted rest parameter (or a symbol instead of a list)
+	newParams := iter
+	for i := len(items) - 1; i >= 0; i-- {
+		newParams = BuildCons(items[i], newParams)
+	}
+
+	body, err := s.rewrite(form.Cdr().Cdr(), local)
+	if err != nil {
+		return nil, err
+	}
+
+	return BuildCons(form.Car(), BuildCons(newParams, body)), nil
+}
+
+// (let ((PATTERN VALUE)...) BODY...): variables are bound in order, so each
+// one is local in the following values and in BODY
+func (s *moduleScope) rewriteLet(form *Value, local map[*Value]bool) (*Value, error) {
+	var declarations []*Value
+	for iter := form.Cdr().Car(); !iter.IsEmptyList(); iter = iter.Cdr() {
+		declaration := iter.Car()
+		if !declaration.IsList() || declaration.ListLength() != 2 {
+			// let reports it
+			declarations = append(declarations, declaration)
+			continue
+		}
+
+		value, err := s.rewrite(declaration.Cdr().Car(), local)
+		if err != nil {
+			return nil, err
+		}
+		declarations = append(declarations, BuildCons(declaration.Car(), BuildCons(value, BuildEmptyList())))
+		local = withLocals(local, patternSymbols(declaration.Car(), nil))
+	}
+
+	body, err := s.rewrite(form.Cdr().Cdr(), local)
+	if err != nil {
+		return nil, err
+	}
+
+	return BuildCons(form.Car(), BuildCons(sliceToList(declarations), body)), nil
+}
+
+// local with syms added. local itself isn't modified since it's shared with
+// the enclosing forms
+func withLocals(local map[*Value]bool, syms []*Value) map[*Value]bool {
+	if len(syms) == 0 {
+		return local
+	}
+
+	result := make(map[*Value]bool, len(local)+len(syms))
+	for sym := range local {
+		result[sym] = true
+	}
+	for _, sym := range syms {
+		result[sym] = true
+	}
+
+	return result
+}
+
+func (s *moduleScope) rewriteSymbol(sym *Value) (*Value, error) {
+	if s.defined[sym] {
+		return qualify(s.name, sym), nil
+	}
+
+	alias, name, qualified := strings.Cut(sym.SymbolName(), "/")
+	module, imported := s.aliases[alias]
+	if !qualified || !imported || name == "" {
+		return sym, nil
+	}
+
+	if !modulesOf(s.bindings).isExported(module, BuildSymbol(name)) {
+		return nil, fmt.Errorf("module %s: %s isn't exported by %s", s.name, sym.SymbolName(), module)
+	}
+
+	return qualify(module, BuildSymbol(name)), nil
+}
@@ -3,6 +3,8 @@ package interpreter
 import (
 	"container/list"
 	"errors"
+	"fmt"
+	"io"
 	"os"
 
 	. "nondv.io/glisp/types"
@@ -16,27 +18,14 @@ func nativeEqual(bindings *Bindings, args *Value) (*Value, error) {
 	if args.ListLength() != 2 {
 		return nil, errors.New("= requires 2 arguments")
 	}
-	args, err := evalArgs(bindings, args)
-	if err != nil {
-		return nil, err
-	}
 
 	a := args.Car()
 	b := args.Cdr().Car()
 
-	if Equal(a, b) {
-		return BuildSymbol("t"), nil
-	} else {
-		return BuildEmptyList(), nil
-	}
+	return BuildBool(Equal(a, b)), nil
 }
 
 func nativeCar(bindings *Bindings, args *Value) (*Value, error) {
-	args, err := evalArgs(bindings, args)
-	if err != nil {
-		return nil, err
-	}
-
 	argument, err := requireOneArg(args)
 	if err != nil {
 		return nil, err
@@ -50,11 +39,6 @@ func nativeCar(bindings *Bindings, args *Value) (*Value, error) {
 }
 
 func nativeCdr(bindings *Bindings, args *Value) (*Value, error) {
-	args, err := evalArgs(bindings, args)
-	if err != nil {
-		return nil, err
-	}
-
 	argument, err := requireOneArg(args)
 	if err != nil {
 		return nil, err
@@ -72,23 +56,13 @@ func nativeCons(bindings *Bindings, args *Value) (*Value, error) {
 		return nil, errors.New("cons requires 2 arguments")
 	}
 
-	car, err := Eval(bindings, args.Car())
-	if err != nil {
-		return nil, err
-	}
-
-	cdr, err := Eval(bindings, args.Cdr().Car())
-	if err != nil {
-		return nil, err
-	}
-
-	return BuildCons(car, cdr), nil
+	return BuildCons(args.Car(), args.Cdr().Car()), nil
 }
 
 func nativePlus(bindings *Bindings, args *Value) (*Value, error) {
-	args, err := evalArgs(bindings, args)
-	if err != nil {
-		return nil, err
+	// the sum of nothing, as TestLisp expects
+	if args.IsEmptyList() {
+		return BuildInteger(0), nil
 	}
 
 	if args.Car().IsInteger() {
@@ -122,19 +96,39 @@ func nativePlus(bindings *Bindings, args *Value) (*Value, error) {
 	return nil, errors.New("type not supported")
 }
 
-func nativeEval(bindings *Bindings, args *Value) (*Value, error) {
-	args, err := evalArgs(bindings, args)
+func nativeKeywordToString(bindings *Bindings, args *Value) (*Value, error) {
+	argument, err := requireOneArg(args)
 	if err != nil {
 		return nil, err
 	}
 
+	if !argument.IsKeyword() {
+		return nil, errors.New("Not a keyword")
+	}
+
+	return BuildString(argument.KeywordName()), nil
+}
+
+func nativeStringToKeyword(bindings *Bindings, args *Value) (*Value, error) {
 	argument, err := requireOneArg(args)
 	if err != nil {
 		return nil, err
 	}
 
-	return Eval(bindings, argument)
+	if !argument.IsString() || argument.ToStr() == "" {
+		return nil, errors.New("Not a non-empty string")
+	}
+
+	return BuildKeyword(argument.ToStr()), nil
+}
+
+func nativeEval(bindings *Bindings, args *Value) (*Value, error) {
+	argument, err := requireOneArg(args)
+	if err != nil {
+		return nil, err
+	}
 
+	return Eval(bindings, argument)
 }
 
 func nativeLet(bindings *Bindings, args *Value) (*Value, error) {
@@ -147,18 +141,28 @@ func nativeLet(bindings *Bindings, args *Value) (*Value, error) {
 	newBindings := bindings
 	for iter := varList; !iter.IsEmptyList(); iter = iter.Cdr() {
 		declaration := iter.Car()
-		if !declaration.IsList() && declaration.ListLength() != 2 {
+		if !declaration.IsList() || declaration.ListLength() != 2 {
 			return nil, errors.New("invalid varlist")
 		}
-		varSym := declaration.Car()
-		if !varSym.IsSymbol() {
-			return nil, errors.New("vars must be symbols")
+		pattern := declaration.Car()
+		if !isPattern(pattern) {
+			return nil, errors.New("vars must be symbols or destructuring patterns")
 		}
 		value, err := Eval(newBindings, declaration.Cdr().Car())
 		if err != nil {
 			return nil, err
 		}
-		newBindings = newBindings.Assoc(varSym, value)
+
+		if pattern.IsSymbol() {
+			newBindings = newBindings.Assoc(pattern, value)
+			continue
+		}
+
+		syms, vals, err := destructure(pattern, value, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		newBindings = newBindings.Extend(syms, vals)
 	}
 
 	result := BuildEmptyList()
@@ -187,23 +191,43 @@ func nativeIf(bindings *Bindings, args *Value) (*Value, error) {
 		return nil, err
 	}
 
-	if !conditionVal.IsEmptyList() {
+	if conditionVal.IsTruthy() {
 		return Eval(bindings, thenBranch)
 	}
 
 	return Eval(bindings, elseBranch)
 }
 
+// Prints every argument on its own line to the runtime's stdout (or stderr
+// if there's no runtime, like Print)
 func nativePrint(bindings *Bindings, args *Value) (*Value, error) {
-	args, err := evalArgs(bindings, args)
-	if err != nil {
-		return nil, err
+	var out io.Writer
+	if rt := bindings.Runtime(); rt != nil {
+		out = rt.Stdout
 	}
 
+	return printArgs(out, args)
+}
+
+// Same as print but to the runtime's stderr
+func nativeEprint(bindings *Bindings, args *Value) (*Value, error) {
+	var out io.Writer
+	if rt := bindings.Runtime(); rt != nil {
+		out = rt.Stderr
+	}
+
+	return printArgs(out, args)
+}
+
+func printArgs(out io.Writer, args *Value) (*Value, error) {
 	lastValue := BuildEmptyList()
-	for iter := args; !iter.IsEmptyList(); iter = args.Cdr() {
+	for iter := args; !iter.IsEmptyList(); iter = iter.Cdr() {
 		lastValue = iter.Car()
-		Print(lastValue)
+		if out == nil {
+			Print(lastValue)
+		} else if _, err := fmt.Fprintln(out, lastValue.PrintStr()); err != nil {
+			return nil, err
+		}
 	}
 
 	return lastValue, nil
@@ -226,10 +250,29 @@ func nativeDefine(bindings *Bindings, args *Value) (*Value, error) {
 		return nil, err
 	}
 
-	// can't just assign directly because the head would be pointing at itself
-	// so first create a copy so the new head points at that
-	bindingsCopy := *bindings
-	*bindings = *bindingsCopy.Assoc(sym, value)
+	bindings.Define(sym, value)
+
+	return value, nil
+}
+
+func nativeSet(bindings *Bindings, args *Value) (*Value, error) {
+	if args.ListLength() != 2 {
+		return nil, errors.New("set! requires 2 arguments")
+	}
+
+	sym := args.Car()
+	if !sym.IsSymbol() {
+		return nil, errors.New("syntax: (set! SYMBOL SEXP)")
+	}
+
+	value, err := Eval(bindings, args.Cdr().Car())
+	if err != nil {
+		return nil, err
+	}
+
+	if !bindings.Set(sym, value) {
+		return nil, errors.New("set!: undefined symbol " + sym.SymbolName())
+	}
 
 	return value, nil
 }
@@ -246,17 +289,26 @@ func nativeLoad(bindings *Bindings, args *Value) (*Value, error) {
 		return nil, errors.New("load requires a string as its argument")
 	}
 
-	contents, err := os.ReadFile(argument.ToStr())
+	contents, err := fileReader(bindings)(argument.ToStr())
 	if err != nil {
 		return nil, err
 	}
 
-	return ReadEvalAll(bindings, string(contents))
+	return readEvalFile(bindings, string(contents))
+}
+
+// The runtime's ReadFile if there is one
+func fileReader(bindings *Bindings) func(path string) ([]byte, error) {
+	if rt := bindings.Runtime(); rt != nil && rt.ReadFile != nil {
+		return rt.ReadFile
+	}
+
+	return os.ReadFile
 }
 
 func evalArgs(bindings *Bindings, args *Value) (*Value, error) {
 	if !args.IsList() {
-		panic("args aren't a list for some reason")
+		return nil, errImproperArgs
 	}
 
 	values := list.New()
@@ -279,7 +331,7 @@ func evalArgs(bindings *Bindings, args *Value) (*Value, error) {
 
 func requireOneArg(args *Value) (*Value, error) {
 	if !args.IsList() {
-		panic("args aren't a list for some reason")
+		return nil, errImproperArgs
 	}
 
 	if args.ListLength() != 1 {
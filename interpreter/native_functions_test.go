@@ -0,0 +1,48 @@
+package interpreter
+
+import (
+	"fmt"
+	"testing"
+
+	. "nondv.io/glisp/types"
+)
+
+// Every call to an applicative native goes through evalArgs
+func BenchmarkEvalArgs(b *testing.B) {
+	bindings := BuildBaseBindings().AssocSym("x", BuildInteger(1))
+
+	for _, n := range []int{1, 4, 16} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			args := BuildEmptyList()
+			for i := 0; i < n; i++ {
+				args = BuildCons(BuildSymbol("x"), args)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := evalArgs(bindings, args); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestImproperArgs(t *testing.T) {
+	bindings := BuildBaseBindings()
+	dotted := BuildCons(BuildInteger(1), BuildInteger(2))
+
+	for _, form := range []*Value{BuildCons(BuildSymbol("+"), dotted), BuildCons(BuildSymbol("car"), BuildInteger(1))} {
+		if _, err := Eval(bindings, form); err != errImproperArgs {
+			t.Errorf("%s: %v", form.PrintStr(), err)
+		}
+	}
+
+	if _, err := evalArgs(bindings, dotted); err != errImproperArgs {
+		t.Errorf("evalArgs: %v", err)
+	}
+	if _, err := requireOneArg(dotted); err != errImproperArgs {
+		t.Errorf("requireOneArg: %v", err)
+	}
+}
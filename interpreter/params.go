@@ -0,0 +1,256 @@
+package interpreter
+
+import (
+	"errors"
+
+	. "nondv.io/glisp/types"
+)
+
+/*
+ * Lambda lists for lambdas with evaluated arguments:
+ *
+ *   (a b)                          - exactly two arguments
+ *   (a &optional b (c 10))         - b defaults to (), c to 10
+ *   (a &rest more) or (a . more)   - more is a list of the remaining arguments
+ *   (a &key b (c 10))              - called as (f 1 :c 3 :b 2)
+ *   ((a b) {:id id})               - required parameters can be destructuring
+ *                                    patterns (see destructure.go)
+ *
+ * Defaults are evaluated when the argument is missing, with the previous
+ * parameters already bound. &rest and &key can be combined, in which case the
+ * rest parameter gets the keyword arguments as a list.
+ */
+
+type lambdaList struct {
+	required []*Value
+	optional []optionalParam
+	rest     *Value
+	keys     []optionalParam
+	// some required parameters are destructuring patterns
+	patterns bool
+}
+
+type optionalParam struct {
+	symbol *Value
+	// default form, () if missing
+	init *Value
+	// only for &key
+	keyword *Value
+}
+
+var (
+	optionalMarker = BuildSymbol("&optional")
+	restMarker     = BuildSymbol("&rest")
+	keyMarker      = BuildSymbol("&key")
+)
+
+// params list -> *lambdaList
+var lambdaListCache FormCache[*lambdaList]
+
+// Returns the parameters if params is a plain list of symbols (no &optional,
+// &rest, &key or dotted tail). Only these are compiled
+func SimpleParams(params *Value) ([]*Value, bool) {
+	list, err := parseLambdaList(params)
+	if err != nil || !list.isSimple() {
+		return nil, false
+	}
+
+	return list.required, true
+}
+
+func (l *lambdaList) isSimple() bool {
+	return len(l.optional) == 0 && l.rest == nil && len(l.keys) == 0 && !l.patterns
+}
+
+func parseLambdaList(params *Value) (*lambdaList, error) {
+	if cached, found := lambdaListCache.Load(params); found {
+		return cached, nil
+	}
+
+	result := &lambdaList{}
+	var section *Value
+	iter := params
+	for ; iter.IsCons(); iter = iter.Cdr() {
+		param := iter.Car()
+
+		switch param {
+		case optionalMarker:
+			if section != nil {
+				return nil, errors.New("&optional must come before &rest and &key")
+			}
+			section = param
+			continue
+		case restMarker:
+			if section == restMarker || section == keyMarker || !iter.Cdr().IsCons() {
+				return nil, errors.New("&rest must be followed by one symbol before &key")
+			}
+			section = param
+			continue
+		case keyMarker:
+			if section == keyMarker {
+				return nil, errors.New("&key can only be used once")
+			}
+			section = param
+			continue
+		}
+
+		switch section {
+		case optionalMarker, keyMarker:
+			opt, err := parseOptionalParam(param)
+			if err != nil {
+				return nil, err
+			}
+			if section == keyMarker {
+				opt.keyword = BuildKeyword(opt.symbol.SymbolName())
+				result.keys = append(result.keys, opt)
+			} else {
+				result.optional = append(result.optional, opt)
+			}
+		case restMarker:
+			if result.rest != nil || !param.IsSymbol() {
+				return nil, errors.New("&rest must be followed by one symbol before &key")
+			}
+			result.rest = param
+		default:
+			if !param.IsSymbol() {
+				if !param.IsCons() && !param.IsHashMap() || !isPattern(param) {
+					return nil, errors.New("parameter is not a symbol")
+				}
+				result.patterns = true
+			}
+			result.required = append(result.required, param)
+		}
+	}
+
+	// (a b . rest)
+	if !iter.IsEmptyList() {
+		if !iter.IsSymbol() || result.rest != nil || len(result.keys) != 0 {
+			return nil, errors.New("dotted parameter must be a symbol and replaces &rest")
+		}
+		result.rest = iter
+	}
+
+	return lambdaListCache.Store(params, result), nil
+}
+
+// Symbols a lambda list binds, whether it's valid or not
+func lambdaListSymbols(params *Value) []*Value {
+	var syms []*Value
+	var section *Value
+	iter := params
+	for ; iter.IsCons(); iter = iter.Cdr() {
+		param := iter.Car()
+		switch {
+		case param == optionalMarker || param == restMarker || param == keyMarker:
+			section = param
+		case (section == optionalMarker || section == keyMarker) && param.IsCons():
+			syms = patternSymbols(param.Car(), syms)
+		default:
+			syms = patternSymbols(param, syms)
+		}
+	}
+
+	// a dotted rest parameter or a symbol instead of the list
+	return patternSymbols(iter, syms)
+}
+
+// SYMBOL or (SYMBOL DEFAULT)
+func parseOptionalParam(param *Value) (optionalParam, error) {
+	if param.IsSymbol() {
+		return optionalParam{symbol: param, init: BuildEmptyList()}, nil
+	}
+
+	if param.IsList() && param.ListLength() == 2 && param.Car().IsSymbol() {
+		return optionalParam{symbol: param.Car(), init: param.Cdr().Car()}, nil
+	}
+
+	return optionalParam{}, errors.New("optional parameter must be SYMBOL or (SYMBOL DEFAULT)")
+}
+
+// Binds evaluated arguments to the parameters in a new frame
+func bindParams(bindings *Bindings, list *lambdaList, args []*Value) (*Bindings, error) {
+	if len(args) < len(list.required) {
+		return nil, errors.New("too many/not enough arguments")
+	}
+	if list.rest == nil && len(list.keys) == 0 && len(args) > len(list.required)+len(list.optional) {
+		return nil, errors.New("too many/not enough arguments")
+	}
+
+	var syms, vals []*Value
+	if list.patterns {
+		for i, param := range list.required {
+			var err error
+			syms, vals, err = destructure(param, args[i], syms, vals)
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		syms = append(syms, list.required...)
+		vals = append(vals, args[:len(list.required)]...)
+	}
+	args = args[len(list.required):]
+
+	for _, opt := range list.optional {
+		var value *Value
+		if len(args) > 0 {
+			value, args = args[0], args[1:]
+		} else {
+			var err error
+			value, err = Eval(bindings.Extend(syms, vals), opt.init)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		syms = append(syms, opt.symbol)
+		vals = append(vals, value)
+	}
+
+	if list.rest != nil {
+		syms = append(syms, list.rest)
+		vals = append(vals, sliceToList(args))
+	}
+
+	if len(list.keys) != 0 {
+		if len(args)%2 != 0 {
+			return nil, errors.New("keyword arguments must come in pairs")
+		}
+
+		given := map[*Value]*Value{}
+		for i := 0; i < len(args); i += 2 {
+			if !list.hasKey(args[i]) {
+				return nil, errors.New("unknown keyword argument " + args[i].PrintStr())
+			}
+			if _, duplicate := given[args[i]]; !duplicate {
+				given[args[i]] = args[i+1]
+			}
+		}
+
+		for _, key := range list.keys {
+			value, found := given[key.keyword]
+			if !found {
+				var err error
+				value, err = Eval(bindings.Extend(syms, vals), key.init)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			syms = append(syms, key.symbol)
+			vals = append(vals, value)
+		}
+	}
+
+	return bindings.Extend(syms, vals), nil
+}
+
+func (l *lambdaList) hasKey(keyword *Value) bool {
+	for _, key := range l.keys {
+		if key.keyword == keyword {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,220 @@
+package interpreter
+
+import (
+	"errors"
+
+	. "nondv.io/glisp/types"
+)
+
+/*
+ * Persistent collections. Unlike vectors and hash maps these are never
+ * modified: every update returns a new version sharing structure with the
+ * old one.
+ */
+
+// (pvector ARGS...)
+func nativePVector(bindings *Bindings, args *Value) (*Value, error) {
+	result := EmptyPVector()
+	for iter := args; !iter.IsEmptyList(); iter = iter.Cdr() {
+		result = result.Conj(iter.Car())
+	}
+
+	return BuildPVector(result), nil
+}
+
+// (pvector-ref PVECTOR INDEX)
+func nativePVectorRef(bindings *Bindings, args *Value) (*Value, error) {
+	if args.ListLength() != 2 {
+		return nil, errors.New("pvector-ref requires 2 arguments")
+	}
+
+	vector, i, err := pvectorAndIndex(args.Car(), args.Cdr().Car(), false)
+	if err != nil {
+		return nil, err
+	}
+
+	return vector.Get(i), nil
+}
+
+// (pvector-assoc PVECTOR INDEX VALUE) - INDEX can be equal to length
+func nativePVectorAssoc(bindings *Bindings, args *Value) (*Value, error) {
+	if args.ListLength() != 3 {
+		return nil, errors.New("pvector-assoc requires 3 arguments")
+	}
+
+	vector, i, err := pvectorAndIndex(args.Car(), args.Cdr().Car(), true)
+	if err != nil {
+		return nil, err
+	}
+
+	return BuildPVector(vector.Assoc(i, args.Cdr().Cdr().Car())), nil
+}
+
+// (pvector-conj PVECTOR VALUE)
+func nativePVectorConj(bindings *Bindings, args *Value) (*Value, error) {
+	if args.ListLength() != 2 {
+		return nil, errors.New("pvector-conj requires 2 arguments")
+	}
+
+	vector := args.Car()
+	if !vector.IsPVector() {
+		return nil, errors.New("Not a persistent vector")
+	}
+
+	return BuildPVector(vector.ToPVector().Conj(args.Cdr().Car())), nil
+}
+
+func nativePVectorLength(bindings *Bindings, args *Value) (*Value, error) {
+	vector, err := pvectorArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return BuildInteger(vector.Len()), nil
+}
+
+func nativePVectorToList(bindings *Bindings, args *Value) (*Value, error) {
+	vector, err := pvectorArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return vector.ToList(), nil
+}
+
+// (pmap KEY VALUE ...)
+func nativePMap(bindings *Bindings, args *Value) (*Value, error) {
+	if args.ListLength()%2 != 0 {
+		return nil, errors.New("pmap requires an even number of arguments")
+	}
+
+	result := EmptyPMap()
+	for iter := args; !iter.IsEmptyList(); iter = iter.Cdr().Cdr() {
+		result = result.Assoc(iter.Car(), iter.Cdr().Car())
+	}
+
+	return BuildPMap(result), nil
+}
+
+// (pmap-get KEY PMAP) or (pmap-get KEY PMAP DEFAULT)
+func nativePMapGet(bindings *Bindings, args *Value) (*Value, error) {
+	length := args.ListLength()
+	if length != 2 && length != 3 {
+		return nil, errors.New("pmap-get requires 2 or 3 arguments")
+	}
+
+	m := args.Cdr().Car()
+	if !m.IsPMap() {
+		return nil, errors.New("pmap-get requires a persistent map")
+	}
+
+	value, found := m.ToPMap().Get(args.Car())
+	if found {
+		return value, nil
+	}
+
+	if length == 3 {
+		return args.Cdr().Cdr().Car(), nil
+	}
+
+	return BuildEmptyList(), nil
+}
+
+// (pmap-assoc KEY VALUE PMAP)
+func nativePMapAssoc(bindings *Bindings, args *Value) (*Value, error) {
+	if args.ListLength() != 3 {
+		return nil, errors.New("pmap-assoc requires 3 arguments")
+	}
+
+	m := args.Cdr().Cdr().Car()
+	if !m.IsPMap() {
+		return nil, errors.New("pmap-assoc requires a persistent map")
+	}
+
+	return BuildPMap(m.ToPMap().Assoc(args.Car(), args.Cdr().Car())), nil
+}
+
+// (pmap-dissoc KEY PMAP)
+func nativePMapDissoc(bindings *Bindings, args *Value) (*Value, error) {
+	if args.ListLength() != 2 {
+		return nil, errors.New("pmap-dissoc requires 2 arguments")
+	}
+
+	m := args.Cdr().Car()
+	if !m.IsPMap() {
+		return nil, errors.New("pmap-dissoc requires a persistent map")
+	}
+
+	return BuildPMap(m.ToPMap().Dissoc(args.Car())), nil
+}
+
+func nativePMapCount(bindings *Bindings, args *Value) (*Value, error) {
+	m, err := pmapArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return BuildInteger(m.Len()), nil
+}
+
+func nativePMapKeys(bindings *Bindings, args *Value) (*Value, error) {
+	m, err := pmapArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return sliceToList(m.Keys()), nil
+}
+
+func nativePMapValues(bindings *Bindings, args *Value) (*Value, error) {
+	m, err := pmapArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return sliceToList(m.Values()), nil
+}
+
+func pvectorAndIndex(vector *Value, index *Value, allowEnd bool) (*PVector, int, error) {
+	if !vector.IsPVector() {
+		return nil, 0, errors.New("Not a persistent vector")
+	}
+
+	if !index.IsInteger() {
+		return nil, 0, errors.New("vector index must be an integer")
+	}
+
+	length := vector.ToPVector().Len()
+	i := index.ToInt()
+	if i < 0 || i > length || (i == length && !allowEnd) {
+		return nil, 0, errors.New("vector index out of range")
+	}
+
+	return vector.ToPVector(), i, nil
+}
+
+func pvectorArg(args *Value) (*PVector, error) {
+	argument, err := requireOneArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if !argument.IsPVector() {
+		return nil, errors.New("Not a persistent vector")
+	}
+
+	return argument.ToPVector(), nil
+}
+
+func pmapArg(args *Value) (*PMap, error) {
+	argument, err := requireOneArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if !argument.IsPMap() {
+		return nil, errors.New("Not a persistent map")
+	}
+
+	return argument.ToPMap(), nil
+}
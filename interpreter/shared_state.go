@@ -0,0 +1,185 @@
+package interpreter
+
+import (
+	"errors"
+
+	. "nondv.io/glisp/types"
+)
+
+/*
+ * Shared mutable state (see types/atom.go and types/stm.go):
+ *
+ *   (atom VALUE)                    (ref VALUE)
+ *   (deref ATOM-OR-REF)             (dosync BODY...)
+ *   (reset! ATOM VALUE)             (ref-set REF VALUE)
+ *   (swap! ATOM F ARGS...)          (alter REF F ARGS...)
+ *   (compare-and-set! ATOM OLD NEW)
+ *
+ *   (mutex)
+ *   (with-lock MUTEX BODY...)
+ *
+ * swap! and dosync may run F (or BODY) several times, so they shouldn't have
+ * side effects.
+ */
+
+// Bound (dynamically) to the current transaction inside dosync
+var transactionSymbol = BuildSymbol("__DOSYNC-TRANSACTION")
+
+func currentTransaction(bindings *Bindings) *Transaction {
+	tx, found := bindings.Lookup(transactionSymbol)
+	if !found {
+		return nil
+	}
+
+	transaction, _ := goObjectArg[*Transaction](tx)
+	return transaction
+}
+
+func nativeAtom(bindings *Bindings, args *Value) (*Value, error) {
+	value, err := requireOneArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return BuildAtom(NewAtom(value)), nil
+}
+
+func nativeDeref(bindings *Bindings, args *Value) (*Value, error) {
+	v, err := requireOneArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case v.IsAtom():
+		return v.ToAtom().Load(), nil
+	case v.IsRef():
+		if tx := currentTransaction(bindings); tx != nil {
+			return tx.Get(v.ToRef())
+		}
+		return v.ToRef().Load(), nil
+	}
+
+	return nil, errors.New("deref: not an atom or a ref: " + v.PrintStr())
+}
+
+func nativeReset(bindings *Bindings, args *Value) (*Value, error) {
+	if args.ListLength() != 2 || !args.Car().IsAtom() {
+		return nil, errors.New("syntax: (reset! ATOM VALUE)")
+	}
+
+	value := args.Cdr().Car()
+	args.Car().ToAtom().Store(value)
+	return value, nil
+}
+
+func nativeSwap(bindings *Bindings, args *Value) (*Value, error) {
+	if args.ListLength() < 2 || !args.Car().IsAtom() {
+		return nil, errors.New("syntax: (swap! ATOM F ARGS...)")
+	}
+
+	fn, extra := args.Cdr().Car(), listToSlice(args.Cdr().Cdr())
+	return args.Car().ToAtom().Swap(func(current *Value) (*Value, error) {
+		return callWithValues(bindings, fn, append([]*Value{current}, extra...))
+	})
+}
+
+func nativeCompareAndSet(bindings *Bindings, args *Value) (*Value, error) {
+	if args.ListLength() != 3 || !args.Car().IsAtom() {
+		return nil, errors.New("syntax: (compare-and-set! ATOM OLD NEW)")
+	}
+
+	old, new := args.Cdr().Car(), args.Cdr().Cdr().Car()
+	return BuildBool(args.Car().ToAtom().CompareAndSwap(old, new)), nil
+}
+
+func nativeMutex(bindings *Bindings, args *Value) (*Value, error) {
+	if !args.IsEmptyList() {
+		return nil, errors.New("mutex takes no arguments")
+	}
+
+	return BuildMutex(&Mutex{}), nil
+}
+
+func nativeWithLock(bindings *Bindings, args *Value) (*Value, error) {
+	if !args.IsCons() {
+		return nil, errors.New("syntax: (with-lock MUTEX BODY...)")
+	}
+
+	m, err := Eval(bindings, args.Car())
+	if err != nil {
+		return nil, err
+	}
+	if !m.IsMutex() {
+		return nil, errors.New("with-lock: not a mutex: " + m.PrintStr())
+	}
+
+	// a cancelled evaluation stops waiting for the lock
+	if err := m.ToMutex().LockContext(evalContext(bindings)); err != nil {
+		return nil, err
+	}
+	defer m.ToMutex().Unlock()
+
+	return evalBody(bindings, args.Cdr())
+}
+
+func nativeRef(bindings *Bindings, args *Value) (*Value, error) {
+	value, err := requireOneArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return BuildRef(NewRef(value)), nil
+}
+
+func nativeDosync(bindings *Bindings, args *Value) (*Value, error) {
+	// nested transactions are part of the outer one
+	if currentTransaction(bindings) != nil {
+		return evalBody(bindings, args)
+	}
+
+	return Dosync(func(tx *Transaction) (*Value, error) {
+		return evalBody(bindings.Assoc(transactionSymbol, BuildGoObject(tx)), args)
+	})
+}
+
+func nativeRefSet(bindings *Bindings, args *Value) (*Value, error) {
+	if args.ListLength() != 2 || !args.Car().IsRef() {
+		return nil, errors.New("syntax: (ref-set REF VALUE)")
+	}
+
+	tx := currentTransaction(bindings)
+	if tx == nil {
+		return nil, errors.New("ref-set: not in a transaction")
+	}
+
+	value := args.Cdr().Car()
+	tx.Set(args.Car().ToRef(), value)
+	return value, nil
+}
+
+func nativeAlter(bindings *Bindings, args *Value) (*Value, error) {
+	if args.ListLength() < 2 || !args.Car().IsRef() {
+		return nil, errors.New("syntax: (alter REF F ARGS...)")
+	}
+
+	tx := currentTransaction(bindings)
+	if tx == nil {
+		return nil, errors.New("alter: not in a transaction")
+	}
+
+	ref := args.Car().ToRef()
+	current, err := tx.Get(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	fn, extra := args.Cdr().Car(), listToSlice(args.Cdr().Cdr())
+	value, err := callWithValues(bindings, fn, append([]*Value{current}, extra...))
+	if err != nil {
+		return nil, err
+	}
+
+	tx.Set(ref, value)
+	return value, nil
+}
@@ -0,0 +1,153 @@
+package interpreter
+
+import (
+	"errors"
+
+	. "nondv.io/glisp/types"
+)
+
+/*
+ * Vectors. vector-set! and vector-push modify the vector in place.
+ */
+
+// (vector ARGS...)
+func nativeVector(bindings *Bindings, args *Value) (*Value, error) {
+	return listToVector(args), nil
+}
+
+// (vector-ref VECTOR INDEX)
+func nativeVectorRef(bindings *Bindings, args *Value) (*Value, error) {
+	if args.ListLength() != 2 {
+		return nil, errors.New("vector-ref requires 2 arguments")
+	}
+
+	vector, i, err := vectorAndIndex(args.Car(), args.Cdr().Car())
+	if err != nil {
+		return nil, err
+	}
+
+	return vector.Get(i), nil
+}
+
+// (vector-set! VECTOR INDEX VALUE)
+func nativeVectorSet(bindings *Bindings, args *Value) (*Value, error) {
+	if args.ListLength() != 3 {
+		return nil, errors.New("vector-set! requires 3 arguments")
+	}
+
+	vector, i, err := vectorAndIndex(args.Car(), args.Cdr().Car())
+	if err != nil {
+		return nil, err
+	}
+
+	value := args.Cdr().Cdr().Car()
+	vector.Set(i, value)
+	return value, nil
+}
+
+func nativeVectorLength(bindings *Bindings, args *Value) (*Value, error) {
+	argument, err := requireOneArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if !argument.IsVector() {
+		return nil, errors.New("Not a vector")
+	}
+
+	return BuildInteger(argument.ToVector().Len()), nil
+}
+
+// (vector-push VECTOR VALUE)
+func nativeVectorPush(bindings *Bindings, args *Value) (*Value, error) {
+	if args.ListLength() != 2 {
+		return nil, errors.New("vector-push requires 2 arguments")
+	}
+
+	vector := args.Car()
+	if !vector.IsVector() {
+		return nil, errors.New("Not a vector")
+	}
+
+	vector.ToVector().Push(args.Cdr().Car())
+	return vector, nil
+}
+
+// (subvector VECTOR START END) - END is exclusive. Returns a copy
+func nativeSubvector(bindings *Bindings, args *Value) (*Value, error) {
+	if args.ListLength() != 3 {
+		return nil, errors.New("subvector requires 3 arguments")
+	}
+
+	vector := args.Car()
+	start := args.Cdr().Car()
+	end := args.Cdr().Cdr().Car()
+	if !vector.IsVector() {
+		return nil, errors.New("Not a vector")
+	}
+	if !start.IsInteger() || !end.IsInteger() {
+		return nil, errors.New("subvector requires integer bounds")
+	}
+
+	items := vector.ToVector().Items
+	from, to := start.ToInt(), end.ToInt()
+	if from < 0 || to > len(items) || from > to {
+		return nil, errors.New("subvector bounds out of range")
+	}
+
+	result := make([]*Value, to-from)
+	copy(result, items[from:to])
+	return BuildVector(result), nil
+}
+
+func nativeListToVector(bindings *Bindings, args *Value) (*Value, error) {
+	argument, err := requireOneArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if !argument.IsList() {
+		return nil, errors.New("Not a list")
+	}
+
+	return listToVector(argument), nil
+}
+
+func nativeVectorToList(bindings *Bindings, args *Value) (*Value, error) {
+	argument, err := requireOneArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if !argument.IsVector() {
+		return nil, errors.New("Not a vector")
+	}
+
+	return argument.ToVector().ToList(), nil
+}
+
+func vectorAndIndex(vector *Value, index *Value) (*Vector, int, error) {
+	if !vector.IsVector() {
+		return nil, 0, errors.New("Not a vector")
+	}
+
+	if !index.IsInteger() {
+		return nil, 0, errors.New("vector index must be an integer")
+	}
+
+	i := index.ToInt()
+	if i < 0 || i >= vector.ToVector().Len() {
+		return nil, 0, errors.New("vector index out of range")
+	}
+
+	return vector.ToVector(), i, nil
+}
+
+func listToVector(lst *Value) *Value {
+	items := make([]*Value, 0, lst.ListLength())
+	for iter := lst; !iter.IsEmptyList(); iter = iter.Cdr() {
+		items = append(items, iter.Car())
+	}
+
+	return BuildVector(items)
+}
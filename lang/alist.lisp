@@ -1,15 +1,17 @@
-(load "lang/core.lisp")
+(module alist (export get set))
 
-(define alist/get
+(require "lang/core.lisp")
+
+(define get
         (lambda (key alist)
           (if (not alist)
               ()
               (let ((cell (car alist)))
                 (if (= key (car cell))
                     (cdr cell)
-                    (alist/get key (cdr alist)))))))
+                    (get key (cdr alist)))))))
 
-(define alist/set
+(define set
         (lambda (key value alist)
           (cons (cons key value)
                 alist)))
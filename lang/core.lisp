@@ -4,7 +4,7 @@
 
 (define quote (lambda quote-ARG (car quote-ARG)))
 
-(define not (lambda (x) (if x () (quote true))))
+(define not (lambda (x) (if x #f #t)))
 
 (define reduce
         (lambda (initial-value f lst)
@@ -18,9 +18,7 @@
               lst
               (cons (f (car lst)) (mapcar f (cdr lst))))))
 
-(define list
-        (lambda list-ARG
-          (mapcar eval list-ARG)))
+(define list (lambda (&rest items) items))
 
 (define reduce
         (lambda (initial-value f lst)
@@ -1,24 +1,45 @@
 package main
 
 import (
-	"errors"
+	"flag"
+	"fmt"
 	"os"
+	"runtime"
+	"time"
 
 	"nondv.io/glisp/interpreter"
+	"nondv.io/glisp/reader"
+	"nondv.io/glisp/snapshot"
 	. "nondv.io/glisp/types"
 )
 
 func main() {
 	bindings := interpreter.BuildBaseBindings()
-	bindings = bindings.Assoc(BuildSymbol("sqr"), BuildNativeFn(nativeSqr))
+	// example of extending the language
+	bindings.Define(BuildSymbol("sqr"), WrapGoFunc(func(n int) int { return n * n }))
+
+	args := os.Args[1:]
+	if len(args) >= 2 && args[0] == "-image" {
+		bindings = loadImage(args[1], snapshot.NativesFrom(bindings))
+		args = args[2:]
+	}
 
 	// No arguments provided
-	if len(os.Args) == 1 {
+	if len(args) == 0 {
 		interpreter.Repl(bindings)
 		return
 	}
 
-	filename := os.Args[1]
+	switch args[0] {
+	case "bench":
+		bench(bindings, args[1:])
+		return
+	case "image":
+		saveImage(bindings, args[1:])
+		return
+	}
+
+	filename := args[0]
 	contents, err := os.ReadFile(filename)
 	if err != nil {
 		panic(err)
@@ -32,16 +53,97 @@ func main() {
 	interpreter.Print(lastResult)
 }
 
-// example of extending the language
-func nativeSqr(bindings *Bindings, args *Value) (*Value, error) {
-	if args.ListLength() != 1 {
-		return nil, errors.New("Only one argument expected")
+// glisp image [-o glisp.img] files.lisp...
+//
+// Evaluates the files and saves the resulting globals, so they can be restored
+// with glisp -image glisp.img ... instead of loading the files again
+func saveImage(bindings *Bindings, args []string) {
+	flags := flag.NewFlagSet("image", flag.ExitOnError)
+	output := flags.String("o", "glisp.img", "image file")
+	flags.Parse(args)
+
+	natives := snapshot.NativesFrom(bindings)
+	for _, filename := range flags.Args() {
+		contents, err := os.ReadFile(filename)
+		if err != nil {
+			panic(err)
+		}
+
+		if _, err := interpreter.ReadEvalAll(bindings, string(contents)); err != nil {
+			panic(err)
+		}
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	if err := snapshot.Save(file, bindings, natives); err != nil {
+		panic(err)
 	}
+}
 
-	if !args.Car().IsInteger() {
-		return nil, errors.New("Integer expected")
+func loadImage(filename string, natives snapshot.Natives) *Bindings {
+	file, err := os.Open(filename)
+	if err != nil {
+		panic(err)
 	}
+	defer file.Close()
+
+	bindings, err := snapshot.Load(file, natives)
+	if err != nil {
+		panic(err)
+	}
+
+	return bindings
+}
+
+// glisp bench [-n N] file.lisp
+//
+// Evaluates the file N times in the same bindings and reports time and
+// allocations per run. Reading the file isn't measured
+func bench(bindings *Bindings, args []string) {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	n := flags.Int("n", 100, "number of runs")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 || *n < 1 {
+		fmt.Fprintln(os.Stderr, "usage: glisp bench [-n N] file.lisp")
+		os.Exit(2)
+	}
+
+	contents, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		panic(err)
+	}
+
+	sexps, err := reader.ReadAll(string(contents))
+	if err != nil {
+		panic(err)
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	for i := 0; i < *n; i++ {
+		for iter := sexps; !iter.IsEmptyList(); iter = iter.Cdr() {
+			if _, err := interpreter.Eval(bindings, iter.Car()); err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
 
-	n := args.Car().ToInt()
-	return BuildInteger(n * n), nil
+	runs := uint64(*n)
+	fmt.Printf("%d runs\t%d ns/op\t%d B/op\t%d allocs/op\n",
+		*n,
+		elapsed.Nanoseconds()/int64(*n),
+		(after.TotalAlloc-before.TotalAlloc)/runs,
+		(after.Mallocs-before.Mallocs)/runs)
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"nondv.io/glisp/interpreter"
+	"nondv.io/glisp/reader"
 	. "nondv.io/glisp/types"
 )
 
@@ -21,11 +22,11 @@ func TestLisp(t *testing.T) {
 	require.Equal(t, "0", readEvalPrintNoErr(bindings, "(+)"))
 	require.Equal(t, "6", readEvalPrintNoErr(bindings, "(+ 1 2 3)"))
 
-	require.Equal(t, "()", readEvalPrintNoErr(bindings, "(= 1 2)"))
-	require.Equal(t, "t", readEvalPrintNoErr(bindings, "(= 1 1)"))
-	require.Equal(t, "()", readEvalPrintNoErr(bindings, "(= 1 -1)"))
-	require.Equal(t, "t", readEvalPrintNoErr(bindings, "(= (lambda x x) (lambda x x))"))
-	require.Equal(t, "()", readEvalPrintNoErr(bindings, "(= (lambda x x) (lambda y y))"))
+	require.Equal(t, "#f", readEvalPrintNoErr(bindings, "(= 1 2)"))
+	require.Equal(t, "#t", readEvalPrintNoErr(bindings, "(= 1 1)"))
+	require.Equal(t, "#f", readEvalPrintNoErr(bindings, "(= 1 -1)"))
+	require.Equal(t, "#t", readEvalPrintNoErr(bindings, "(= (lambda x x) (lambda x x))"))
+	require.Equal(t, "#f", readEvalPrintNoErr(bindings, "(= (lambda x x) (lambda y y))"))
 
 	require.Equal(t, "bla",
 		readEvalPrintNoErr(bindings, "((lambda X (car X)) bla)"))
@@ -89,6 +90,139 @@ func TestLisp(t *testing.T) {
 	require.Equal(t, "(1 2)", readEvalPrintNoErr(bindings, code))
 }
 
+func TestBool(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+
+	require.Equal(t, "#t", readEvalPrintNoErr(bindings, "#t"))
+	require.Equal(t, "#f", readEvalPrintNoErr(bindings, "#f"))
+	require.Equal(t, "#t", readEvalPrintNoErr(bindings, "t"))
+	require.Equal(t, "#f", readEvalPrintNoErr(bindings, "false"))
+	require.Equal(t, "#t", readEvalPrintNoErr(bindings, "(= #f false)"))
+	require.Equal(t, "#f", readEvalPrintNoErr(bindings, "(= #f ())"))
+
+	// only () and #f are false
+	require.Equal(t, "2", readEvalPrintNoErr(bindings, "(if #f 1 2)"))
+	require.Equal(t, "2", readEvalPrintNoErr(bindings, "(if () 1 2)"))
+	require.Equal(t, "1", readEvalPrintNoErr(bindings, "(if #t 1 2)"))
+	require.Equal(t, "1", readEvalPrintNoErr(bindings, "(if 0 1 2)"))
+	require.Equal(t, "1", readEvalPrintNoErr(bindings, `(if "" 1 2)`))
+
+	_, err := readEval(bindings, `(load "lang/core.lisp")`)
+	require.NoError(t, err)
+	require.Equal(t, "#t", readEvalPrintNoErr(bindings, "(not ())"))
+	require.Equal(t, "#t", readEvalPrintNoErr(bindings, "(not #f)"))
+	require.Equal(t, "#f", readEvalPrintNoErr(bindings, "(not 1)"))
+	require.Equal(t, "#t", readEvalPrintNoErr(bindings, "(= (not ()) (= 1 1))"))
+
+	require.True(t, BuildBool(true) == BuildBool(1 == 1))
+	require.False(t, BuildBool(false).IsTruthy())
+	require.False(t, BuildEmptyList().IsTruthy())
+	require.True(t, BuildInteger(0).IsTruthy())
+}
+
+func TestKeyword(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+
+	require.Equal(t, ":status", readEvalPrintNoErr(bindings, ":status"))
+	require.Equal(t, "#t", readEvalPrintNoErr(bindings, "(= :a :a)"))
+	require.Equal(t, "#f", readEvalPrintNoErr(bindings, "(= :a :b)"))
+	require.Equal(t, "#f", readEvalPrintNoErr(bindings, `(= :a "a")`))
+	require.Equal(t, `"status"`, readEvalPrintNoErr(bindings, "(keyword->string :status)"))
+	require.Equal(t, "#t", readEvalPrintNoErr(bindings, `(= :body (string->keyword "body"))`))
+	require.Equal(t, "200", readEvalPrintNoErr(bindings, "(hash-get :status {:status 200 :body 1})"))
+
+	require.True(t, BuildKeyword("x") == BuildKeyword("x"))
+
+	_, err := readEval(bindings, `(keyword->string "a")`)
+	require.NotNil(t, err)
+}
+
+func TestHashMap(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+
+	require.Equal(t, "{}", readEvalPrintNoErr(bindings, "{}"))
+	require.Equal(t, `{"a" 3 "b" 2}`, readEvalPrintNoErr(bindings, `{"a" (+ 1 2) "b" 2}`))
+	require.Equal(t, "#t", readEvalPrintNoErr(bindings, `(= {"a" 1 "b" 2} {"b" 2 "a" 1})`))
+	require.Equal(t, "#f", readEvalPrintNoErr(bindings, `(= {"a" 1} {"a" 2})`))
+	// vector keys are looked up by identity but compared by value
+	require.Equal(t, "#t", readEvalPrintNoErr(bindings, "(= {[1] 1} {[1] 1})"))
+	require.Equal(t, "#t", readEvalPrintNoErr(bindings, "(= {[1] 1 [1] 2} {[1] 2 [1] 1})"))
+	require.Equal(t, "#f", readEvalPrintNoErr(bindings, "(= {[1] 1 [1] 1} {[1] 1 [1] 2})"))
+	require.Equal(t, "#t", readEvalPrintNoErr(bindings, "(= {{} 1} {{} 1})"))
+
+	// every key is evaluated, even if the forms are equal
+	readEvalPrintNoErr(bindings, "(define n 0)")
+	require.Equal(t, "{1 1 2 2}", readEvalPrintNoErr(bindings, "{(set! n (+ n 1)) 1 (set! n (+ n 1)) 2}"))
+	require.Equal(t, "2", readEvalPrintNoErr(bindings, "n"))
+
+	code := `(let ((h {"a" 1 (cons 1 (cons 2 ())) 2}))
+                  (+ (hash-get "a" h) (hash-get (cons 1 (cons 2 ())) h)))`
+	require.Equal(t, "3", readEvalPrintNoErr(bindings, code))
+	require.Equal(t, "()", readEvalPrintNoErr(bindings, `(hash-get "x" {"a" 1})`))
+	require.Equal(t, "5", readEvalPrintNoErr(bindings, `(hash-get "x" {"a" 1} 5)`))
+
+	code = `(let ((h {"a" 1}))
+                  (hash-set "b" 2 h)
+                  (hash-set "a" 3 h)
+                  h)`
+	require.Equal(t, `{"a" 3 "b" 2}`, readEvalPrintNoErr(bindings, code))
+	require.Equal(t, `{"b" 2}`, readEvalPrintNoErr(bindings, `(hash-delete "a" {"a" 1 "b" 2})`))
+	require.Equal(t, `{"a" 1}`, readEvalPrintNoErr(bindings, `(hash-delete "x" {"a" 1})`))
+
+	require.Equal(t, `("a" "b")`, readEvalPrintNoErr(bindings, `(hash-keys {"a" 1 "b" 2})`))
+	require.Equal(t, `(1 2)`, readEvalPrintNoErr(bindings, `(hash-values {"a" 1 "b" 2})`))
+	require.Equal(t, "2", readEvalPrintNoErr(bindings, `(hash-count {"a" 1 "b" 2})`))
+	require.Equal(t, `(("a" . 1) ("b" . 2))`, readEvalPrintNoErr(bindings, `(hash->alist {"a" 1 "b" 2})`))
+
+	code = `(alist->hash (cons (cons "a" 1) (cons (cons "b" 2) (cons (cons "a" 3) ()))))`
+	require.Equal(t, `{"a" 1 "b" 2}`, readEvalPrintNoErr(bindings, code))
+
+	// changing a key doesn't lose the entry
+	code = `(let ((k {"a" 1}) (v [1]))
+                  (let ((h {k 1 v 2 (cons v ()) 3}))
+                    (hash-set "b" 2 k)
+                    (vector-push v 2)
+                    [(hash-get k h) (hash-get v h) (hash-get (cons v ()) h)]))`
+	require.Equal(t, "[1 2 3]", readEvalPrintNoErr(bindings, code))
+
+	_, err := readEval(bindings, `(hash-get "a" 1)`)
+	require.NotNil(t, err)
+}
+
+func TestVector(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+
+	require.Equal(t, "[]", readEvalPrintNoErr(bindings, "[]"))
+	require.Equal(t, "[1 3 [4]]", readEvalPrintNoErr(bindings, "[1 (+ 1 2) [4]]"))
+	require.Equal(t, "[1 2 3]", readEvalPrintNoErr(bindings, "(vector 1 2 (+ 1 2))"))
+	require.Equal(t, "#t", readEvalPrintNoErr(bindings, "(= [1 [2]] [1 [2]])"))
+	require.Equal(t, "#f", readEvalPrintNoErr(bindings, "(= [1 2] [1 2 3])"))
+	require.Equal(t, "#f", readEvalPrintNoErr(bindings, "(= [1 2] (cons 1 (cons 2 ())))"))
+
+	require.Equal(t, "20", readEvalPrintNoErr(bindings, "(vector-ref [10 20 30] 1)"))
+	require.Equal(t, "3", readEvalPrintNoErr(bindings, "(vector-length [10 20 30])"))
+
+	code := `(let ((v [1 2 3]))
+                  (vector-set! v 0 100)
+                  (vector-push v 4)
+                  v)`
+	require.Equal(t, "[100 2 3 4]", readEvalPrintNoErr(bindings, code))
+	require.Equal(t, "[2 3]", readEvalPrintNoErr(bindings, "(subvector [1 2 3 4] 1 3)"))
+	require.Equal(t, "[]", readEvalPrintNoErr(bindings, "(subvector [1 2 3 4] 2 2)"))
+
+	require.Equal(t, "(1 2 3)", readEvalPrintNoErr(bindings, "(vector->list [1 2 3])"))
+	require.Equal(t, "[1 2]", readEvalPrintNoErr(bindings, "(list->vector (cons 1 (cons 2 ())))"))
+
+	// vectors are mutable so they're hash keys by identity
+	require.Equal(t, "2", readEvalPrintNoErr(bindings, "(let ((k [1 2])) (hash-get k {k 2}))"))
+	require.Equal(t, "()", readEvalPrintNoErr(bindings, "(hash-get [1 2] {[1 2] 2})"))
+
+	_, err := readEval(bindings, "(vector-ref [1 2] 2)")
+	require.NotNil(t, err)
+	_, err = readEval(bindings, "(subvector [1 2] 1 3)")
+	require.NotNil(t, err)
+}
+
 func TestNoLet(t *testing.T) {
 	bindings := interpreter.BuildBaseBindings()
 	withQuote := func(code string) string {
@@ -100,8 +234,21 @@ func TestNoLet(t *testing.T) {
 }
 
 
+// Evaluator used by readEval and readEvalPrintNoErr. TestVMSuite runs the
+// tests with vm.Eval instead
+var evalForm = interpreter.Eval
+
+func readEval(bindings *Bindings, txt string) (*Value, error) {
+	sexp, err := reader.Read(txt)
+	if err != nil {
+		return nil, err
+	}
+
+	return evalForm(bindings, sexp)
+}
+
 func readEvalPrintNoErr(bindings *Bindings, txt string) string {
-	res, err := interpreter.ReadEval(bindings, txt)
+	res, err := readEval(bindings, txt)
 	if err != nil {
 		println(err.Error())
 		panic("err!")
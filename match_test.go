@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"nondv.io/glisp/interpreter"
+)
+
+func TestMatch(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	readEval(bindings, `(load "lang/core.lisp")`)
+
+	cases := map[string]string{
+		`(match 1 (1 "one") (2 "two"))`:                       `"one"`,
+		`(match "b" ("a" 1) ("b" 2))`:                         "2",
+		`(match :get (:post 1) (:get 2))`:                     "2",
+		`(match () ((x) 1) (() 2))`:                           "2",
+		`(match #f (#t 1) (#f 2))`:                            "2",
+		`(match 5 (x (+ x 1)))`:                               "6",
+		`(match (list 1 2) ((_ x) x))`:                        "2",
+		`(match (quote foo) ((quote bar) 1) ((quote foo) 2))`: "2",
+		`(match (list 1 2 3) ((a . rest) rest))`:              "(2 3)",
+		`(match (list 1 (list 2 3)) ((a (b c)) (+ a b c)))`:   "6",
+		`(match (list 1 2) ((a) 1) ((a b c) 3) ((a b) 2))`:    "2",
+		`(match {"a" 1} ({"a" 2} 2) ({"a" x} x))`:             "1",
+		`(match 5 (x :when (= x 4) 1) (x :when (= x 5) 2))`:   "2",
+		`(match 1 (_))`:                                       "()",
+	}
+
+	for code, expected := range cases {
+		require.Equal(t, expected, readEvalPrintNoErr(bindings, code), code)
+	}
+}
+
+func TestMatchPredicates(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	readEval(bindings, `(load "lang/core.lisp")`)
+	readEval(bindings, `(define small? (lambda (x) (= x 1)))`)
+
+	require.Equal(t, `"small"`, readEvalPrintNoErr(bindings, `(match 1 ((? small?) "small") (_ "big"))`))
+	require.Equal(t, `"big"`, readEvalPrintNoErr(bindings, `(match 2 ((? small?) "small") (_ "big"))`))
+	require.Equal(t, "(1)", readEvalPrintNoErr(bindings, `(match (list 1) (((? small? n)) (list n)))`))
+
+	// the predicate gets the value, not a form to evaluate
+	require.Equal(t, "a", readEvalPrintNoErr(bindings, `(match (list (quote a) (quote b)) ((? (lambda X X) args) (car args)))`))
+}
+
+func TestMatchRouting(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	readEval(bindings, `(load "lang/core.lisp")`)
+	readEval(bindings, `(define route
+	                                  (lambda (method path)
+	                                    (match (list method path)
+	                                      (("GET" ("users" id)) (list "show" id))
+	                                      (("DELETE" ("users" id)) :when (= id "1") "forbidden")
+	                                      (("DELETE" ("users" id)) (list "delete" id))
+	                                      (_ "not found"))))`)
+
+	require.Equal(t, `("show" "5")`, readEvalPrintNoErr(bindings, `(route "GET" (list "users" "5"))`))
+	require.Equal(t, `"forbidden"`, readEvalPrintNoErr(bindings, `(route "DELETE" (list "users" "1"))`))
+	require.Equal(t, `("delete" "2")`, readEvalPrintNoErr(bindings, `(route "DELETE" (list "users" "2"))`))
+	require.Equal(t, `"not found"`, readEvalPrintNoErr(bindings, `(route "POST" (list "users"))`))
+}
+
+func TestMatchErrors(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	readEval(bindings, `(load "lang/core.lisp")`)
+
+	_, err := readEval(bindings, `(match (list 1 2) ((a) 1) (3 3))`)
+	require.EqualError(t, err, "match: no clause matched (1 2)")
+
+	for _, code := range []string{
+		"(match)",
+		"(match 1 2)",
+		"(match 1 ([x] 1))",
+		"(match 1 ((? ) 1))",
+		"(match 1 (x :when))",
+		"(match 1 ((? undefined-predicate) 1))",
+	} {
+		_, err := readEval(bindings, code)
+		require.NotNil(t, err, code)
+	}
+}
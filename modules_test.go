@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"nondv.io/glisp/interpreter"
+)
+
+// An interpreter with the files written to a temporary load path
+func moduleInterpreter(t *testing.T, files map[string]string) *interpreter.Interpreter {
+	dir := t.TempDir()
+	for name, contents := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644))
+	}
+
+	in := interpreter.New(interpreter.WithLoadPath(dir))
+	_, err := in.Eval(context.Background(), "(define loads (atom 0))")
+	require.NoError(t, err)
+	return in
+}
+
+func evalModules(t *testing.T, in *interpreter.Interpreter, code string) string {
+	result, err := in.Eval(context.Background(), code)
+	require.NoError(t, err, code)
+	return result.PrintStr()
+}
+
+func TestModules(t *testing.T) {
+	in := moduleInterpreter(t, map[string]string{
+		"greetings.lisp": `
+          (module greetings (export greet))
+          (swap! loads + 1)
+          (define prefix "Hello, ")
+          (define greet (lambda (name) (+ prefix name)))`,
+	})
+
+	require.Equal(t, "greetings", evalModules(t, in, "(import greetings :as g)"))
+	require.Equal(t, `"Hello, world"`, evalModules(t, in, `(g/greet "world")`))
+	require.Equal(t, `"Hello, world"`, evalModules(t, in, `(greetings/greet "world")`))
+
+	// definitions don't collide with globals of the same name
+	evalModules(t, in, `(define prefix "Bye, ")`)
+	require.Equal(t, `"Hello, world"`, evalModules(t, in, `(g/greet "world")`))
+
+	// only exports are available through aliases
+	_, err := in.Eval(context.Background(), "g/prefix")
+	require.EqualError(t, err, "Undefined")
+	require.Equal(t, `"Hello, "`, evalModules(t, in, "greetings/prefix"))
+
+	// modules are loaded once
+	evalModules(t, in, "(import greetings)")
+	evalModules(t, in, `(require "greetings.lisp")`)
+	require.Equal(t, "1", evalModules(t, in, "(deref loads)"))
+}
+
+func TestModuleImports(t *testing.T) {
+	in := moduleInterpreter(t, map[string]string{
+		"math.lisp": `
+          (module math (export twice))
+          (define twice (lambda (f x) (f (f x))))`,
+		"numbers.lisp": `
+          (module numbers (export add-four))
+          (import math :as m)
+          (define add-two (lambda (x) (+ x 2)))
+          (define add-four (lambda (x) (m/twice add-two x)))`,
+		"leaky.lisp": `
+          (module leaky)
+          (import math :as m)
+          (define oops (lambda () (m/secret)))`,
+	})
+
+	require.Equal(t, "5", evalModules(t, in, "(import numbers :as n) (n/add-four 1)"))
+	_, err := in.Eval(context.Background(), "(n/add-two 1)")
+	require.EqualError(t, err, "Undefined")
+
+	// the bookkeeping isn't in the globals
+	for sym := range in.Bindings().Globals() {
+		require.False(t, strings.HasPrefix(sym.SymbolName(), "__"), sym.SymbolName())
+	}
+
+	// aliases imported by modules are theirs only
+	_, err = in.Eval(context.Background(), "(m/twice n/add-four 0)")
+	require.EqualError(t, err, "Undefined")
+
+	_, err = in.Eval(context.Background(), "(import leaky)")
+	require.EqualError(t, err, "module leaky: m/secret isn't exported by math")
+
+	// forks share the loaded modules but not the aliases imported since
+	fork := in.Fork()
+	require.Equal(t, "9", evalModules(t, fork, "(import math :as m) (m/twice n/add-four 1)"))
+	_, err = in.Eval(context.Background(), "(m/twice n/add-four 0)")
+	require.EqualError(t, err, "Undefined")
+	in.ReplaceGlobals(fork)
+	require.Equal(t, "8", evalModules(t, in, "(m/twice n/add-four 0)"))
+}
+
+// Only references to the module's definitions are rewritten
+func TestModuleShadowing(t *testing.T) {
+	in := moduleInterpreter(t, map[string]string{
+		"shadow.lisp": `
+          (module shadow)
+          (define get (lambda (x) (cons :get x)))
+          (define set 10)
+          (define data (quote (set x)))
+          (define identity (lambda (get) get))
+          (define rest (lambda (a . set) set))
+          (define optional (lambda (&optional (x set) &key (y get)) (cons x y)))
+          (define local (let ((get 1) (set (+ get 1))) (cons get set)))
+          (define outer (let ((x set)) (get x)))`,
+	})
+	_, err := in.Eval(context.Background(), "(define quote (lambda args (car args)))")
+	require.NoError(t, err)
+	evalModules(t, in, "(import shadow :as s)")
+
+	require.Equal(t, "(set x)", evalModules(t, in, "s/data"))
+	require.Equal(t, "5", evalModules(t, in, "(s/identity 5)"))
+	require.Equal(t, "(2 3)", evalModules(t, in, "(s/rest 1 2 3)"))
+	require.Equal(t, "(10 lambda (x) (cons :get x))", evalModules(t, in, "(s/optional)"))
+	require.Equal(t, "(1 . 2)", evalModules(t, in, "s/local"))
+	require.Equal(t, "(:get . 10)", evalModules(t, in, "s/outer"))
+}
+
+func TestModuleErrors(t *testing.T) {
+	in := moduleInterpreter(t, map[string]string{
+		"a.lisp":       "(module a) (import b) (define x 1)",
+		"b.lisp":       "(module b) (import a) (define y 2)",
+		"self.lisp":    `(require "self.lisp")`,
+		"missing.lisp": "(module missing (export nope)) (define x 1)",
+		"other.lisp":   "(module something-else)",
+	})
+
+	_, err := in.Eval(context.Background(), "(import a)")
+	require.EqualError(t, err, "circular import: a.lisp -> b.lisp -> a.lisp")
+
+	_, err = in.Eval(context.Background(), `(require "self.lisp")`)
+	require.EqualError(t, err, "circular import: self.lisp -> self.lisp")
+
+	_, err = in.Eval(context.Background(), "(import missing)")
+	require.EqualError(t, err, "module missing: exported nope isn't defined")
+
+	_, err = in.Eval(context.Background(), "(import other)")
+	require.EqualError(t, err, "import: other.lisp doesn't define module other")
+
+	_, err = in.Eval(context.Background(), "(import)")
+	require.EqualError(t, err, "syntax: (import NAME [:as ALIAS])")
+}
+
+func TestAlistModule(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	readEvalPrintNoErr(bindings, `(require "lang/alist.lisp")`)
+	readEvalPrintNoErr(bindings, "(import alist :as a)")
+
+	require.Equal(t, "2", readEvalPrintNoErr(bindings, `(a/get :b (a/set :b 2 (a/set :a 1 ())))`))
+	require.Equal(t, "()", readEvalPrintNoErr(bindings, `(alist/get :c (a/set :a 1 ()))`))
+}
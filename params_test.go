@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"nondv.io/glisp/interpreter"
+	"nondv.io/glisp/reader"
+	"nondv.io/glisp/vm"
+)
+
+func TestRestParams(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+
+	require.Equal(t, "(1 (2 3))", readEvalPrintNoErr(bindings, "((lambda (a . rest) (cons a (cons rest ()))) 1 2 (+ 1 2))"))
+	require.Equal(t, "(1 ())", readEvalPrintNoErr(bindings, "((lambda (a &rest rest) (cons a (cons rest ()))) 1)"))
+	require.Equal(t, "(1 2)", readEvalPrintNoErr(bindings, "((lambda args args) 1 2)"))
+
+	// arguments are evaluated in the caller's environment
+	code := `(let ((x 10)
+	               (f (lambda (&rest xs) xs)))
+	           (f x (+ x 1)))`
+	require.Equal(t, "(10 11)", readEvalPrintNoErr(bindings, code))
+
+	_, err := readEval(bindings, "((lambda (a . rest) a))")
+	require.NotNil(t, err)
+}
+
+func TestOptionalParams(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	readEval(bindings, "(define f (lambda (a &optional b (c (+ a 10))) (cons a (cons b (cons c ())))))")
+
+	require.Equal(t, "(1 () 11)", readEvalPrintNoErr(bindings, "(f 1)"))
+	require.Equal(t, "(1 2 11)", readEvalPrintNoErr(bindings, "(f 1 2)"))
+	require.Equal(t, "(1 2 3)", readEvalPrintNoErr(bindings, "(f 1 2 3)"))
+
+	_, err := readEval(bindings, "(f 1 2 3 4)")
+	require.NotNil(t, err)
+	_, err = readEval(bindings, "(f)")
+	require.NotNil(t, err)
+}
+
+func TestKeyParams(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	readEval(bindings, "(define f (lambda (a &key b (c 3)) (cons a (cons b (cons c ())))))")
+
+	require.Equal(t, "(1 () 3)", readEvalPrintNoErr(bindings, "(f 1)"))
+	require.Equal(t, "(1 2 30)", readEvalPrintNoErr(bindings, "(f 1 :c 30 :b 2)"))
+	require.Equal(t, "(1 2 3)", readEvalPrintNoErr(bindings, "(f 1 :b (+ 1 1))"))
+
+	for _, code := range []string{"(f 1 :b)", "(f 1 :d 4)", "(f 1 2)"} {
+		_, err := readEval(bindings, code)
+		require.NotNil(t, err, code)
+	}
+
+	code := "((lambda (&rest opts &key verbose) (cons verbose opts)) :verbose #t)"
+	require.Equal(t, "(#t :verbose #t)", readEvalPrintNoErr(bindings, code))
+}
+
+func TestInvalidLambdaLists(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+
+	for _, code := range []string{
+		"((lambda (&rest) 1))",
+		"((lambda (&rest a b) 1) 1 2)",
+		"((lambda (&key a &optional b) 1))",
+		"((lambda (&optional (a)) 1))",
+		"((lambda (a &key b . c) 1) 1)",
+	} {
+		_, err := readEval(bindings, code)
+		require.NotNil(t, err, code)
+	}
+}
+
+func TestCompiledLambdaLists(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+
+	cases := []string{
+		"((lambda (a . rest) (cons a rest)) 1 2 3)",
+		"((lambda (a &optional (b (+ a 1))) (cons a b)) 1)",
+		"((lambda (&key x) x) :x 5)",
+	}
+	for _, code := range cases {
+		expected := readEvalPrintNoErr(bindings, code)
+		require.Equal(t, expected, compileRunPrintNoErr(bindings, code), code)
+		require.Equal(t, expected, vmRunPrintNoErr(bindings, code), code)
+	}
+
+	for _, code := range []string{"(lambda (a &rest b) a)", "(lambda (a . b) a)"} {
+		form, _ := reader.Read(code)
+		_, err := vm.CompileLambda(form)
+		require.NotNil(t, err, code)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"nondv.io/glisp/interpreter"
+	. "nondv.io/glisp/types"
+)
+
+func TestPVector(t *testing.T) {
+	n := 5000
+	versions := []*PVector{EmptyPVector()}
+	for i := 0; i < n; i++ {
+		versions = append(versions, versions[i].Conj(BuildInteger(i)))
+	}
+
+	// older versions are untouched
+	for _, size := range []int{0, 1, 32, 33, 1024, 1025, 1057, n} {
+		v := versions[size]
+		require.Equal(t, size, v.Len())
+		for i := 0; i < size; i++ {
+			require.Equal(t, i, v.Get(i).ToInt())
+		}
+	}
+
+	full := versions[n]
+	updated := full
+	for i := 0; i < n; i += 7 {
+		updated = updated.Assoc(i, BuildInteger(-i))
+	}
+	for i := 0; i < n; i++ {
+		require.Equal(t, i, full.Get(i).ToInt())
+		if i%7 == 0 {
+			require.Equal(t, -i, updated.Get(i).ToInt())
+		} else {
+			require.Equal(t, i, updated.Get(i).ToInt())
+		}
+	}
+
+	require.Equal(t, n+1, full.Assoc(n, BuildInteger(0)).Len())
+	require.Panics(t, func() { full.Get(n) })
+}
+
+func TestPMap(t *testing.T) {
+	n := 5000
+	m := EmptyPMap()
+	for i := 0; i < n; i++ {
+		m = m.Assoc(BuildInteger(i), BuildInteger(i*2))
+	}
+	require.Equal(t, n, m.Len())
+
+	one, _ := m.Get(BuildInteger(1))
+	require.Equal(t, m, m.Assoc(BuildInteger(1), one))
+	same := m.Assoc(BuildInteger(1), BuildInteger(0))
+	require.Equal(t, n, same.Len())
+
+	smaller := m
+	for i := 0; i < n; i += 2 {
+		smaller = smaller.Dissoc(BuildInteger(i))
+	}
+	require.Equal(t, n/2, smaller.Len())
+	require.Equal(t, smaller, smaller.Dissoc(BuildInteger(0)))
+
+	for i := 0; i < n; i++ {
+		value, found := m.Get(BuildInteger(i))
+		require.True(t, found)
+		require.Equal(t, i*2, value.ToInt())
+
+		_, found = smaller.Get(BuildInteger(i))
+		require.Equal(t, i%2 == 1, found)
+	}
+
+	_, found := m.Get(BuildString("0"))
+	require.False(t, found)
+	require.Len(t, smaller.Keys(), n/2)
+}
+
+func TestPersistentCollections(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+
+	require.Equal(t, "#[1 3]", readEvalPrintNoErr(bindings, "#[1 (+ 1 2)]"))
+	require.Equal(t, "#[1 2 3]", readEvalPrintNoErr(bindings, "(pvector 1 2 3)"))
+	require.Equal(t, "2", readEvalPrintNoErr(bindings, "(pvector-ref #[1 2 3] 1)"))
+	require.Equal(t, "3", readEvalPrintNoErr(bindings, "(pvector-length #[1 2 3])"))
+	require.Equal(t, "(1 2)", readEvalPrintNoErr(bindings, "(pvector->list #[1 2])"))
+
+	code := `(let ((v #[1 2 3])
+                       (w (pvector-assoc v 0 100))
+                       (x (pvector-conj w 4)))
+                  (cons v (cons w (cons x ()))))`
+	require.Equal(t, "(#[1 2 3] #[100 2 3] #[100 2 3 4])", readEvalPrintNoErr(bindings, code))
+	require.Equal(t, "#t", readEvalPrintNoErr(bindings, "(= #[1 [2]] (pvector 1 [2]))"))
+	require.Equal(t, "#f", readEvalPrintNoErr(bindings, "(= #[1 2] [1 2])"))
+
+	require.Equal(t, `#{"a" 3}`, readEvalPrintNoErr(bindings, `#{"a" (+ 1 2)}`))
+	readEvalPrintNoErr(bindings, "(define n 0)")
+	require.Equal(t, "2", readEvalPrintNoErr(bindings, "(pmap-count #{(set! n (+ n 1)) 1 (set! n (+ n 1)) 2})"))
+	require.Equal(t, "1", readEvalPrintNoErr(bindings, `(pmap-get "a" (pmap "a" 1 "b" 2))`))
+	require.Equal(t, "()", readEvalPrintNoErr(bindings, `(pmap-get "x" #{"a" 1})`))
+	require.Equal(t, "0", readEvalPrintNoErr(bindings, `(pmap-get "x" #{"a" 1} 0)`))
+	require.Equal(t, "2", readEvalPrintNoErr(bindings, `(pmap-count (pmap-assoc "b" 2 #{"a" 1}))`))
+
+	code = `(let ((m #{"a" 1 "b" 2})
+                       (n (pmap-dissoc "a" m)))
+                  (cons (pmap-count m) (pmap-count n)))`
+	require.Equal(t, "(2 . 1)", readEvalPrintNoErr(bindings, code))
+	require.Equal(t, "#t", readEvalPrintNoErr(bindings, `(= #{"a" 1 "b" 2} (pmap "b" 2 "a" 1))`))
+	require.Equal(t, "#f", readEvalPrintNoErr(bindings, `(= #{"a" 1} {"a" 1})`))
+	require.Equal(t, "#t", readEvalPrintNoErr(bindings, "(= #{[1] 1} #{[1] 1})"))
+	require.Equal(t, "#f", readEvalPrintNoErr(bindings, "(= #{[1] 1} #{[1] 2})"))
+	require.Equal(t, `("a")`, readEvalPrintNoErr(bindings, `(pmap-keys #{"a" 1})`))
+	require.Equal(t, `(1)`, readEvalPrintNoErr(bindings, `(pmap-values #{"a" 1})`))
+
+	_, err := readEval(bindings, "(pvector-ref #[1] 1)")
+	require.NotNil(t, err)
+}
@@ -15,11 +15,15 @@ type noNextTokenError struct{}
 type NoNextSexpError struct{}
 type UnfinishedSexpError struct {}
 type UnfinishedStringError struct {}
+type OddHashMapError struct{}
+type DottedListError struct{}
 
 func (e *noNextTokenError) Error() string { return "Reader couldn't find next token" }
 func (e *NoNextSexpError) Error() string  { return "No sexp found" }
 func (e *UnfinishedSexpError) Error() string { return "closing paren missing" }
 func (e *UnfinishedStringError) Error() string { return "closing quote missing" }
+func (e *OddHashMapError) Error() string { return "hash map literal requires an even number of forms" }
+func (e *DottedListError) Error() string { return "dot must be followed by exactly one form at the end of a list" }
 
 // Returns a list of sexps
 func ReadAll(txt string) (*Value, error) {
@@ -73,21 +77,133 @@ func parseNext(runes []rune) (*Value, int, error) {
 		return parseList(runes)
 	}
 
+	if token == "{" {
+		return parseHashMap(runes)
+	}
+
+	if token == "[" {
+		return parseVector(runes)
+	}
+
+	if token == "#[" {
+		return parsePVector(runes)
+	}
+
+	if token == "#{" {
+		return parsePMap(runes)
+	}
+
 	value, err := tokenToValue(token)
 	return value, endIndex, err
 }
 
 func parseList(runes []rune) (*Value, int, error) {
+	values, i, err := parseSequence(runes, "(", ")")
+	if err != nil {
+		return nil, i, err
+	}
+
+	// (a b . c)
+	result := BuildEmptyList()
+	last := values.Back()
+	if last != nil && last.Prev() != nil && last.Prev().Value.(*Value) == dot {
+		if last.Prev().Prev() == nil {
+			return nil, i, &DottedListError{}
+		}
+		result = last.Value.(*Value)
+		last = last.Prev().Prev()
+	}
+
+	for e := last; e != nil; e = e.Prev() {
+		if e.Value.(*Value) == dot {
+			return nil, i, &DottedListError{}
+		}
+		result = BuildCons(e.Value.(*Value), result)
+	}
+
+	return result, i, nil
+}
+
+var dot = BuildSymbol(".")
+
+func parseHashMap(runes []rune) (*Value, int, error) {
+	values, i, err := parseSequence(runes, "{", "}")
+	if err != nil {
+		return nil, i, err
+	}
+
+	if values.Len()%2 != 0 {
+		return nil, i, &OddHashMapError{}
+	}
+
+	var keys, vals []*Value
+	for e := values.Front(); e != nil; e = e.Next().Next() {
+		keys = append(keys, e.Value.(*Value))
+		vals = append(vals, e.Next().Value.(*Value))
+	}
+
+	return BuildHashMap(NewHashMapLiteral(keys, vals)), i, nil
+}
+
+func parseVector(runes []rune) (*Value, int, error) {
+	values, i, err := parseSequence(runes, "[", "]")
+	if err != nil {
+		return nil, i, err
+	}
+
+	items := make([]*Value, 0, values.Len())
+	for e := values.Front(); e != nil; e = e.Next() {
+		items = append(items, e.Value.(*Value))
+	}
+
+	return BuildVector(items), i, nil
+}
+
+func parsePVector(runes []rune) (*Value, int, error) {
+	values, i, err := parseSequence(runes, "#[", "]")
+	if err != nil {
+		return nil, i, err
+	}
+
+	result := EmptyPVector()
+	for e := values.Front(); e != nil; e = e.Next() {
+		result = result.Conj(e.Value.(*Value))
+	}
+
+	return BuildPVector(result), i, nil
+}
+
+func parsePMap(runes []rune) (*Value, int, error) {
+	values, i, err := parseSequence(runes, "#{", "}")
+	if err != nil {
+		return nil, i, err
+	}
+
+	if values.Len()%2 != 0 {
+		return nil, i, &OddHashMapError{}
+	}
+
+	var keys, vals []*Value
+	for e := values.Front(); e != nil; e = e.Next().Next() {
+		keys = append(keys, e.Value.(*Value))
+		vals = append(vals, e.Next().Value.(*Value))
+	}
+
+	return BuildPMap(NewPMapLiteral(keys, vals)), i, nil
+}
+
+// Reads sexps between opening and closing tokens (in order)
+func parseSequence(runes []rune, opening string, closing string) (*list.List, int, error) {
 	token, i, err := nextToken(runes)
-	if err != nil || token != "(" {
-		panic("parseList didn't find an opening paren")
+	if err != nil || token != opening {
+		panic("parseSequence didn't find an opening token")
 	}
 
 	values := list.New()
-	token, maybeClosingParenOffset, _ := nextToken(runes[i+1:])
-	for token != ")" {
+	token, maybeClosingOffset, _ := nextToken(runes[i+1:])
+	for token != closing {
 		val, iOffset, err := parseNext(runes[i+1:])
-		values.PushFront(val)
+		values.PushBack(val)
 		i += 1 + iOffset
 		if err != nil {
 			if _, ok := err.(*NoNextSexpError); ok {
@@ -96,19 +212,14 @@ func parseList(runes []rune) (*Value, int, error) {
 			return nil, i, err
 		}
 
-		token, maybeClosingParenOffset, _ = nextToken(runes[i+1:])
-	}
-
-	result := BuildEmptyList()
-	for e := values.Front(); e != nil; e = e.Next() {
-		result = BuildCons(e.Value.(*Value), result)
+		token, maybeClosingOffset, _ = nextToken(runes[i+1:])
 	}
 
-	return result, i + 1 + maybeClosingParenOffset, nil
+	return values, i + 1 + maybeClosingOffset, nil
 }
 
 func tokenToValue(token string) (*Value, error) {
-	if token == "(" || token == ")" {
+	if len(token) == 1 && isParen(rune(token[0])) {
 		return nil, errors.New("Can't convert to value")
 	}
 
@@ -120,6 +231,14 @@ func tokenToValue(token string) (*Value, error) {
 		return BuildInteger(value), nil
 	}
 
+	if token == "#t" || token == "#f" {
+		return BuildBool(token == "#t"), nil
+	}
+
+	if token[0] == ':' && len(token) > 1 {
+		return BuildKeyword(token[1:]), nil
+	}
+
 	if token[0] == '"' {
 		return BuildString(prepareString(token)), nil
 	}
@@ -168,6 +287,11 @@ func nextToken(runes []rune) (string, int, error) {
 		return string(runes[i]), i, nil
 	}
 
+	// persistent collections: #[...] and #{...}
+	if runes[i] == '#' && i+1 < len(runes) && (runes[i+1] == '[' || runes[i+1] == '{') {
+		return string(runes[i : i+2]), i + 1, nil
+	}
+
 	if runes[i] == '"' {
 		beginIndex := i
 		i++
@@ -189,5 +313,5 @@ func nextToken(runes []rune) (string, int, error) {
 }
 
 func isParen(r rune) bool {
-	return r == '(' || r == ')'
+	return r == '(' || r == ')' || r == '{' || r == '}' || r == '[' || r == ']'
 }
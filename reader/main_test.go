@@ -1,7 +1,9 @@
 package reader
 
 import (
+	"fmt"
 	"github.com/stretchr/testify/require"
+	"strings"
 	"testing"
 
 	. "nondv.io/glisp/types"
@@ -15,6 +17,10 @@ func TestSymbol(t *testing.T) {
 	// These aren't reserved
 	requireSymbol(t, "nil", readNoErr("nil"))
 	requireSymbol(t, "t", readNoErr("t"))
+
+	// interned
+	require.True(t, readNoErr("hello") == BuildSymbol("hello"))
+	require.True(t, readNoErr("(a)").Car() == readNoErr("a"))
 }
 
 func TestInteger(t *testing.T) {
@@ -27,6 +33,25 @@ func TestInteger(t *testing.T) {
 	requireSymbol(t, "--123", readNoErr(" --123"))
 }
 
+func TestBool(t *testing.T) {
+	value := readNoErr("#t")
+	require.True(t, value.IsBool())
+	require.True(t, value.ToBool())
+
+	value = readNoErr("#f")
+	require.True(t, value.IsBool())
+	require.False(t, value.ToBool())
+}
+
+func TestKeyword(t *testing.T) {
+	value := readNoErr(":status")
+	require.True(t, value.IsKeyword())
+	require.Equal(t, "status", value.KeywordName())
+	require.True(t, value == readNoErr(" :status "))
+
+	requireSymbol(t, ":", readNoErr(":"))
+}
+
 func TestList(t *testing.T) {
 	requireEmptyList(t, readNoErr("()"))
 	requireEmptyList(t, readNoErr("(    \n   )"))
@@ -72,6 +97,72 @@ func TestString(t *testing.T) {
 	requireString(t, "hello,\n\"world\"", readNoErr(code))
 }
 
+func TestHashMap(t *testing.T) {
+	value := readNoErr("{}")
+	require.True(t, value.IsHashMap())
+	require.Equal(t, 0, value.ToHashMap().Len())
+
+	value = readNoErr(`{"a" 1 b (c d)}`)
+	require.True(t, value.IsHashMap())
+	hash := value.ToHashMap()
+	require.Equal(t, 2, hash.Len())
+	a, found := hash.Get(BuildString("a"))
+	require.True(t, found)
+	requireInteger(t, 1, a)
+	b, found := hash.Get(BuildSymbol("b"))
+	require.True(t, found)
+	require.Equal(t, 2, b.ListLength())
+
+	value = readNoErr(`({1 2} 3)`)
+	require.True(t, value.Car().IsHashMap())
+
+	// equal key forms are kept for evaluation
+	value = readNoErr(`{(f) 1 (f) 2}`)
+	require.Equal(t, 1, value.ToHashMap().Len())
+	keys, values := value.ToHashMap().LiteralEntries()
+	require.Len(t, keys, 2)
+	requireInteger(t, 1, values[0])
+	requireInteger(t, 2, values[1])
+
+	_, err := Read(`{"a" 1 "b"}`)
+	require.IsType(t, &OddHashMapError{}, err)
+
+	_, err = Read(`{"a" 1`)
+	require.NotNil(t, err)
+}
+
+func TestVector(t *testing.T) {
+	value := readNoErr("[]")
+	require.True(t, value.IsVector())
+	require.Equal(t, 0, value.ToVector().Len())
+
+	value = readNoErr("[1 (2 3) [4]]")
+	require.True(t, value.IsVector())
+	vector := value.ToVector()
+	require.Equal(t, 3, vector.Len())
+	requireInteger(t, 1, vector.Get(0))
+	require.Equal(t, 2, vector.Get(1).ListLength())
+	require.True(t, vector.Get(2).IsVector())
+
+	_, err := Read("[1 2")
+	require.NotNil(t, err)
+}
+
+func TestPersistentCollections(t *testing.T) {
+	value := readNoErr("#[1 2 #[3]]")
+	require.True(t, value.IsPVector())
+	require.Equal(t, 3, value.ToPVector().Len())
+	require.True(t, value.ToPVector().Get(2).IsPVector())
+
+	value = readNoErr(`#{"a" 1}`)
+	require.True(t, value.IsPMap())
+	a, found := value.ToPMap().Get(BuildString("a"))
+	require.True(t, found)
+	requireInteger(t, 1, a)
+
+	requireSymbol(t, "#abc", readNoErr("#abc"))
+}
+
 func TestReadAll(t *testing.T) {
 	sexps, err := ReadAll("(hello-world)")
 	require.NoError(t, err)
@@ -135,3 +226,35 @@ func readNoErr(txt string) *Value {
 	val, _ := Read(txt)
 	return val
 }
+
+func TestDottedList(t *testing.T) {
+	require.Equal(t, "(a b . c)", readNoErr("(a b . c)").PrintStr())
+	require.Equal(t, "(a . c)", readNoErr("(a . c)").PrintStr())
+	require.Equal(t, "(a b c)", readNoErr("(a b . (c))").PrintStr())
+
+	for _, code := range []string{"(. a)", "(a . b c)", "(a .)", "(a . . b)"} {
+		_, err := Read(code)
+		require.IsType(t, &DottedListError{}, err, code)
+	}
+}
+
+// Roughly what a big source file looks like
+func BenchmarkReadAll(b *testing.B) {
+	for _, n := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			var sb strings.Builder
+			for i := 0; i < n; i++ {
+				fmt.Fprintf(&sb, "(define fn-%d\n  (lambda (x y) ; comment\n    (if (= x %d) \"string %d\" (cons :kw [x y {\"k\" #t}]))))\n", i, i, i)
+			}
+			input := sb.String()
+			b.SetBytes(int64(len(input)))
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := ReadAll(input); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+// Package reload keeps an interpreter in sync with the .lisp files it was
+// loaded from:
+//
+//	in := interpreter.New()
+//	reloader := reload.New(in, []string{"lang/core.lisp", "router.lisp"},
+//		reload.OnError(func(err error) { log.Println(err) }))
+//	if err := reloader.Load(ctx); err != nil {
+//		log.Fatal(err)
+//	}
+//	go reloader.Run(ctx)
+//
+// Files are watched by polling their modification times (and sizes). Every
+// file read while evaluating them, including with load, is watched too. When
+// any of them changes, all files are evaluated again, in order, in a fresh
+// environment: a fork of the interpreter as it was when New was called. Only
+// if that succeeds its globals replace the interpreter's (atomically, see
+// Interpreter.ReplaceGlobals), so a broken file never takes down working code.
+package reload
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"nondv.io/glisp/interpreter"
+)
+
+const DefaultInterval = time.Second
+
+type Reloader struct {
+	target *interpreter.Interpreter
+	base   *interpreter.Interpreter
+	files  []string
+
+	interval time.Duration
+	onReload func(changed []string)
+	onError  func(err error)
+
+	// serializes reloads
+	mu sync.Mutex
+	// watched files (entry files and their dependencies)
+	watched map[string]fileState
+
+	metricsMu sync.Mutex
+	metrics   Metrics
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+type Metrics struct {
+	// Successful reloads, including the initial Load
+	Reloads int
+	// Reloads that failed and were discarded
+	Failures int
+	// When the last successful reload finished
+	LastReload time.Time
+	// How long the last reload (successful or not) took
+	LastDuration time.Duration
+	// nil if the last reload succeeded
+	LastError error
+}
+
+type Option func(*Reloader)
+
+// How often Run checks the files. DefaultInterval by default
+func WithInterval(d time.Duration) Option {
+	return func(r *Reloader) { r.interval = d }
+}
+
+// Called after every successful reload with the files that changed (all of
+// them for the initial Load)
+func OnReload(f func(changed []string)) Option {
+	return func(r *Reloader) { r.onReload = f }
+}
+
+// Called when a reload fails. The previous code keeps running
+func OnError(f func(err error)) Option {
+	return func(r *Reloader) { r.onError = f }
+}
+
+// Watches files (evaluated in this order) for in. The environment files are
+// evaluated in is a fork of in made now, so New should be called before
+// loading any of them
+func New(in *interpreter.Interpreter, files []string, options ...Option) *Reloader {
+	r := &Reloader{
+		target:   in,
+		base:     in.Fork(),
+		files:    append([]string{}, files...),
+		interval: DefaultInterval,
+		watched:  make(map[string]fileState),
+	}
+
+	for _, option := range options {
+		option(r)
+	}
+
+	return r
+}
+
+// Evaluates the files for the first time. Returns the error instead of only
+// reporting it
+func (r *Reloader) Load(ctx context.Context) error {
+	return r.Reload(ctx)
+}
+
+// Reloads unconditionally
+func (r *Reloader) Reload(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.reload(ctx, r.files)
+}
+
+// Reloads if any watched file has changed. Returns whether it did and the
+// error if the reload failed
+func (r *Reloader) Check(ctx context.Context) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changed := r.changedFiles()
+	if len(changed) == 0 {
+		return false, nil
+	}
+
+	return true, r.reload(ctx, changed)
+}
+
+// Checks the files every interval until ctx is done
+func (r *Reloader) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Check(ctx)
+		}
+	}
+}
+
+// Files being watched, sorted
+func (r *Reloader) Files() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	files := make([]string, 0, len(r.watched))
+	for path := range r.watched {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+
+	return files
+}
+
+func (r *Reloader) Metrics() Metrics {
+	r.metricsMu.Lock()
+	defer r.metricsMu.Unlock()
+
+	return r.metrics
+}
+
+func (r *Reloader) changedFiles() []string {
+	var changed []string
+	for path, state := range r.watched {
+		if current := stat(path); current.exists != state.exists || current.size != state.size || !current.modTime.Equal(state.modTime) {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+
+	return changed
+}
+
+func stat(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+
+	return fileState{info.ModTime(), info.Size(), true}
+}
+
+// Has to be called with mu locked
+func (r *Reloader) reload(ctx context.Context, changed []string) error {
+	start := time.Now()
+
+	// entry files are watched even if they can't be read
+	read := make(map[string]fileState)
+	for _, path := range r.files {
+		read[path] = stat(path)
+	}
+
+	// spawned code can load files too. The hook runs before the file is
+	// read, so a change made while reloading is noticed by the next Check
+	var readMu sync.Mutex
+	env := r.base.Fork(interpreter.WithFileHook(func(path string) {
+		readMu.Lock()
+		defer readMu.Unlock()
+		read[path] = stat(path)
+	}))
+
+	var err error
+	for _, path := range r.files {
+		if _, err = env.EvalFile(ctx, path); err != nil {
+			err = fmt.Errorf("reload: %s: %w", path, err)
+			break
+		}
+	}
+
+	// dependencies of a failed reload are watched too, so fixing any of
+	// them triggers another one. The old ones are kept: the error may have
+	// happened before they were loaded
+	if err != nil {
+		for path, state := range read {
+			r.watched[path] = state
+		}
+	} else {
+		r.watched = read
+		r.target.ReplaceGlobals(env)
+	}
+
+	r.metricsMu.Lock()
+	r.metrics.LastDuration = time.Since(start)
+	r.metrics.LastError = err
+	if err != nil {
+		r.metrics.Failures++
+	} else {
+		r.metrics.Reloads++
+		r.metrics.LastReload = time.Now()
+	}
+	r.metricsMu.Unlock()
+
+	if err != nil && r.onError != nil {
+		r.onError(err)
+	}
+	if err == nil && r.onReload != nil {
+		r.onReload(changed)
+	}
+
+	return err
+}
@@ -0,0 +1,162 @@
+package reload
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"nondv.io/glisp/interpreter"
+)
+
+// Writes the file and moves its modification time forward so the change is
+// noticed regardless of the filesystem's timestamp granularity
+func writeFile(t *testing.T, path, contents string) {
+	var modTime time.Time
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime().Add(time.Second)
+	} else {
+		modTime = time.Now()
+	}
+
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func evalPrint(t *testing.T, in *interpreter.Interpreter, code string) string {
+	result, err := in.Eval(context.Background(), code)
+	require.NoError(t, err, code)
+	return result.PrintStr()
+}
+
+func TestReload(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	main, dependency := filepath.Join(dir, "main.lisp"), filepath.Join(dir, "dependency.lisp")
+	writeFile(t, dependency, `(define greeting "hello")`)
+	writeFile(t, main, `(load "`+dependency+`") (define greet (lambda () greeting))`)
+
+	var reloaded [][]string
+	var errs []error
+	in := interpreter.New()
+	r := New(in, []string{main},
+		OnReload(func(changed []string) { reloaded = append(reloaded, changed) }),
+		OnError(func(err error) { errs = append(errs, err) }))
+
+	require.NoError(t, r.Load(ctx))
+	require.Equal(t, `"hello"`, evalPrint(t, in, "(greet)"))
+	require.Equal(t, []string{dependency, main}, r.Files())
+	require.Equal(t, [][]string{{main}}, reloaded)
+
+	changed, err := r.Check(ctx)
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	// dependencies are watched too
+	writeFile(t, dependency, `(define greeting "hi")`)
+	changed, err = r.Check(ctx)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, `"hi"`, evalPrint(t, in, "(greet)"))
+	require.Equal(t, []string{dependency}, reloaded[1])
+
+	// broken code doesn't replace working code
+	writeFile(t, dependency, `(define greeting`)
+	changed, err = r.Check(ctx)
+	require.True(t, changed)
+	require.Error(t, err)
+	require.Len(t, errs, 1)
+	require.True(t, errors.Is(errs[0], err))
+	require.Equal(t, `"hi"`, evalPrint(t, in, "(greet)"))
+
+	metrics := r.Metrics()
+	require.Equal(t, 2, metrics.Reloads)
+	require.Equal(t, 1, metrics.Failures)
+	require.Equal(t, err, metrics.LastError)
+
+	// nor is it retried until it changes
+	changed, _ = r.Check(ctx)
+	require.False(t, changed)
+
+	writeFile(t, dependency, `(define greeting "hey")`)
+	changed, err = r.Check(ctx)
+	require.True(t, changed)
+	require.NoError(t, err)
+	require.Equal(t, `"hey"`, evalPrint(t, in, "(greet)"))
+	require.Nil(t, r.Metrics().LastError)
+}
+
+func TestReloadStartsFromBase(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "main.lisp")
+	writeFile(t, path, `(define old 1)`)
+
+	in := interpreter.New()
+	plus, _ := in.Get("+")
+	in.Define("base", plus)
+	r := New(in, []string{path})
+	require.NoError(t, r.Load(ctx))
+
+	writeFile(t, path, `(define new 2)`)
+	require.NoError(t, r.Reload(ctx))
+
+	// definitions removed from the files are gone, the ones made before New
+	// are kept
+	_, err := in.Eval(ctx, "old")
+	require.Error(t, err)
+	require.Equal(t, "2", evalPrint(t, in, "new"))
+	require.Equal(t, "3", evalPrint(t, in, "(base 1 2)"))
+}
+
+func TestReloadMissingFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "main.lisp")
+
+	in := interpreter.New()
+	r := New(in, []string{path})
+	require.Error(t, r.Load(ctx))
+
+	// appearing is a change too
+	writeFile(t, path, `(define x 1)`)
+	changed, err := r.Check(ctx)
+	require.True(t, changed)
+	require.NoError(t, err)
+	require.Equal(t, "1", evalPrint(t, in, "x"))
+}
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "main.lisp")
+	writeFile(t, path, `(define x 1)`)
+
+	reloaded := make(chan []string, 1)
+	in := interpreter.New()
+	r := New(in, []string{path},
+		WithInterval(time.Millisecond),
+		OnReload(func(changed []string) { reloaded <- changed }))
+	require.NoError(t, r.Load(ctx))
+	<-reloaded
+
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+
+	writeFile(t, path, `(define x 2)`)
+	select {
+	case changed := <-reloaded:
+		require.Equal(t, []string{path}, changed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("file wasn't reloaded")
+	}
+	require.Equal(t, "2", evalPrint(t, in, "x"))
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"nondv.io/glisp/interpreter"
+	. "nondv.io/glisp/types"
+)
+
+func TestAtoms(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	readEval(bindings, `(load "lang/core.lisp")`)
+
+	readEvalPrintNoErr(bindings, "(define counter (atom 0))")
+	require.Equal(t, "#<atom 0>", readEvalPrintNoErr(bindings, "counter"))
+	require.Equal(t, "1", readEvalPrintNoErr(bindings, "(swap! counter + 1)"))
+	require.Equal(t, "11", readEvalPrintNoErr(bindings, "(swap! counter (lambda (n by) (+ n by)) 10)"))
+	require.Equal(t, "11", readEvalPrintNoErr(bindings, "(deref counter)"))
+	require.Equal(t, "#f", readEvalPrintNoErr(bindings, "(compare-and-set! counter 0 5)"))
+	require.Equal(t, "#t", readEvalPrintNoErr(bindings, "(compare-and-set! counter 11 5)"))
+	require.Equal(t, "(1 2)", readEvalPrintNoErr(bindings, "(reset! counter (list 1 2))"))
+	require.Equal(t, "(1 2)", readEvalPrintNoErr(bindings, "(deref counter)"))
+
+	// from many goroutines
+	readEvalPrintNoErr(bindings, "(reset! counter 0)")
+	readEvalPrintNoErr(bindings, `
+      (mapcar (lambda (f) (await f))
+              (mapcar (lambda (_) (spawn (swap! counter + 1)))
+                      (list 1 2 3 4 5 6 7 8 9 10)))`)
+	require.Equal(t, "10", readEvalPrintNoErr(bindings, "(deref counter)"))
+
+	_, err := readEval(bindings, "(deref 1)")
+	require.EqualError(t, err, "deref: not an atom or a ref: 1")
+}
+
+func TestWithLock(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	readEval(bindings, `(load "lang/core.lisp")`)
+
+	readEvalPrintNoErr(bindings, "(define lock (mutex))")
+	readEvalPrintNoErr(bindings, "(define total (vector 0))")
+	require.Equal(t, "#<mutex>", readEvalPrintNoErr(bindings, "lock"))
+
+	readEvalPrintNoErr(bindings, `
+      (mapcar (lambda (f) (await f))
+              (mapcar (lambda (_) (spawn (with-lock lock (vector-set! total 0 (+ 1 (vector-ref total 0))))))
+                      (list 1 2 3 4 5 6 7 8 9 10)))`)
+	require.Equal(t, "10", readEvalPrintNoErr(bindings, "(vector-ref total 0)"))
+
+	_, err := readEval(bindings, "(with-lock 1 2)")
+	require.EqualError(t, err, "with-lock: not a mutex: 1")
+	// waiting for the lock stops when the evaluation is cancelled
+	in := interpreter.New()
+	in.Define("lock", BuildMutex(&Mutex{}))
+	held, _ := in.Get("lock")
+	held.ToMutex().Lock()
+	defer held.ToMutex().Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = in.Eval(ctx, "(with-lock lock 1)")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestDosync(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	readEval(bindings, `(load "lang/core.lisp")`)
+
+	readEvalPrintNoErr(bindings, "(define from (ref 10))")
+	readEvalPrintNoErr(bindings, "(define to (ref 0))")
+	readEvalPrintNoErr(bindings, `
+      (define transfer
+              (lambda ()
+                (dosync
+                  (alter from + -1)
+                  (alter to + 1))))`)
+
+	readEvalPrintNoErr(bindings, `
+      (mapcar (lambda (f) (await f))
+              (mapcar (lambda (_) (spawn (transfer)))
+                      (list 1 2 3 4 5 6 7 8 9 10)))`)
+	require.Equal(t, "(0 10)", readEvalPrintNoErr(bindings, "(list (deref from) (deref to))"))
+
+	// changes inside a transaction are visible to it (and nested ones) only
+	require.Equal(t, "(5 10)", readEvalPrintNoErr(bindings, "(dosync (ref-set from 5) (dosync (list (deref from) (deref to))))"))
+	require.Equal(t, "#<ref 5>", readEvalPrintNoErr(bindings, "from"))
+
+	_, err := readEval(bindings, "(ref-set from 1)")
+	require.EqualError(t, err, "ref-set: not in a transaction")
+	_, err = readEval(bindings, "(alter from + 1)")
+	require.EqualError(t, err, "alter: not in a transaction")
+}
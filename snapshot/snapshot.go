@@ -0,0 +1,539 @@
+// Package snapshot saves global bindings to a binary image and restores them,
+// which is much faster than evaluating the code that created them:
+//
+//	bindings := interpreter.BuildBaseBindings()
+//	natives := snapshot.NativesFrom(bindings) // before evaluating anything
+//	interpreter.ReadEval(bindings, `(load "lang/core.lisp")`)
+//	err := snapshot.Save(file, bindings, natives)
+//
+//	restored, err := snapshot.Load(file, snapshot.DefaultNatives())
+//
+// Images contain all globals and everything reachable from them: lambdas,
+// data, symbols, atoms and refs (with their current values). Native functions
+// are stored by name and looked up in a Natives table when loading. Values
+// shared (or referenced cyclically) by several globals stay shared. Go objects
+// can't be saved.
+//
+// Format (version 1): the magic "GLISPIMG", the version and the number of
+// globals as uvarints, then a name/value pair per global. Values are a tag
+// byte followed by the payload; every value except booleans and
+// back-references gets the next id, in the order they appear, so repeated
+// values are written once and referenced by id afterwards.
+package snapshot
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"nondv.io/glisp/interpreter"
+	. "nondv.io/glisp/types"
+)
+
+const (
+	magic   = "GLISPIMG"
+	Version = 1
+)
+
+const (
+	tagRef byte = iota
+	tagSymbol
+	tagKeyword
+	tagInteger
+	tagString
+	tagTrue
+	tagFalse
+	tagEmptyList
+	tagCons
+	tagNative
+	tagHashMap
+	tagVector
+	tagPVector
+	tagPMap
+	tagAtom
+	tagMutex
+	tagRefCell
+)
+
+// Native functions by name
+type Natives map[string]*Value
+
+// Every native function bound to a global in b. A native bound to several
+// names is stored under the first one alphabetically
+func NativesFrom(b *Bindings) Natives {
+	natives := Natives{}
+	for sym, value := range b.Globals() {
+		if value.IsNativeFn() {
+			natives[sym.SymbolName()] = value
+		}
+	}
+
+	return natives
+}
+
+// Natives of interpreter.BuildBaseBindings
+func DefaultNatives() Natives {
+	return NativesFrom(interpreter.BuildBaseBindings())
+}
+
+// Returned by Load when an image refers to a native that isn't in the table
+type MissingNativeError struct {
+	Name string
+}
+
+func (e *MissingNativeError) Error() string {
+	return "snapshot: missing native " + e.Name
+}
+
+var ErrNotImage = errors.New("snapshot: not a glisp image")
+
+type VersionError struct {
+	Version uint64
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("snapshot: unsupported image version %d (supported: %d)", e.Version, Version)
+}
+
+// Writes the globals of b to w. Every native function reachable from them has
+// to be in natives (natives are matched by identity, so the table should come
+// from the same bindings, e.g. NativesFrom(b) before evaluating any code)
+func Save(w io.Writer, b *Bindings, natives Natives) error {
+	enc := &encoder{
+		w:           bufio.NewWriter(w),
+		ids:         make(map[any]uint64),
+		nativeNames: make(map[*NativeFn]string),
+	}
+	for name, native := range natives {
+		fn := native.ToNativeFn()
+		if existing, found := enc.nativeNames[fn]; !found || name < existing {
+			enc.nativeNames[fn] = name
+		}
+	}
+
+	globals := b.Globals()
+	syms := make([]*Value, 0, len(globals))
+	for sym := range globals {
+		syms = append(syms, sym)
+	}
+	sort.Slice(syms, func(i, j int) bool { return syms[i].SymbolName() < syms[j].SymbolName() })
+
+	enc.w.WriteString(magic)
+	enc.uint(Version)
+	enc.uint(uint64(len(syms)))
+	for _, sym := range syms {
+		if err := enc.value(sym); err != nil {
+			return err
+		}
+		if err := enc.value(globals[sym]); err != nil {
+			return fmt.Errorf("global %s: %w", sym.SymbolName(), err)
+		}
+	}
+
+	return enc.w.Flush()
+}
+
+// Reads an image written by Save into new bindings
+func Load(r io.Reader, natives Natives) (*Bindings, error) {
+	dec := &decoder{r: bufio.NewReader(r), natives: natives}
+
+	header := make([]byte, len(magic))
+	if _, err := io.ReadFull(dec.r, header); err != nil || string(header) != magic {
+		return nil, ErrNotImage
+	}
+
+	version, err := dec.uint()
+	if err != nil {
+		return nil, err
+	}
+	if version != Version {
+		return nil, &VersionError{version}
+	}
+
+	count, err := dec.uint()
+	if err != nil {
+		return nil, err
+	}
+
+	b := NewBindings()
+	for i := uint64(0); i < count; i++ {
+		sym, err := dec.value()
+		if err != nil {
+			return nil, err
+		}
+		if !sym.IsSymbol() {
+			return nil, errors.New("snapshot: corrupted image: global name isn't a symbol")
+		}
+
+		value, err := dec.value()
+		if err != nil {
+			return nil, fmt.Errorf("global %s: %w", sym.SymbolName(), err)
+		}
+		b.Define(sym, value)
+	}
+
+	return b, nil
+}
+
+type encoder struct {
+	w *bufio.Writer
+	// *Value, or the underlying container for mutable values -> id
+	ids         map[any]uint64
+	nativeNames map[*NativeFn]string
+}
+
+func (e *encoder) uint(n uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	e.w.Write(buf[:binary.PutUvarint(buf[:], n)])
+}
+
+func (e *encoder) string(s string) {
+	e.uint(uint64(len(s)))
+	e.w.WriteString(s)
+}
+
+// Mutable values are identified by what they wrap, so two *Value pointing to
+// the same vector restore as one vector
+func identity(v *Value) any {
+	if v.IsHashMap() || v.IsVector() || v.IsAtom() || v.IsMutex() || v.IsRef() || v.IsNativeFn() {
+		return v.Value
+	}
+
+	return v
+}
+
+func (e *encoder) value(v *Value) error {
+	if v.IsBool() {
+		if v.ToBool() {
+			e.w.WriteByte(tagTrue)
+		} else {
+			e.w.WriteByte(tagFalse)
+		}
+		return nil
+	}
+
+	if id, found := e.ids[identity(v)]; found {
+		e.w.WriteByte(tagRef)
+		e.uint(id)
+		return nil
+	}
+	e.ids[identity(v)] = uint64(len(e.ids))
+
+	switch {
+	case v.IsSymbol():
+		e.w.WriteByte(tagSymbol)
+		e.string(v.SymbolName())
+	case v.IsKeyword():
+		e.w.WriteByte(tagKeyword)
+		e.string(v.KeywordName())
+	case v.IsInteger():
+		var buf [binary.MaxVarintLen64]byte
+		e.w.WriteByte(tagInteger)
+		e.w.Write(buf[:binary.PutVarint(buf[:], int64(v.ToInt()))])
+	case v.IsString():
+		e.w.WriteByte(tagString)
+		e.string(v.ToStr())
+	case v.IsEmptyList():
+		e.w.WriteByte(tagEmptyList)
+	case v.IsCons():
+		e.w.WriteByte(tagCons)
+		if err := e.value(v.Car()); err != nil {
+			return err
+		}
+		return e.value(v.Cdr())
+	case v.IsNativeFn():
+		name, found := e.nativeNames[v.ToNativeFn()]
+		if !found {
+			return errors.New("snapshot: native fn isn't registered")
+		}
+		e.w.WriteByte(tagNative)
+		e.string(name)
+	case v.IsHashMap():
+		e.w.WriteByte(tagHashMap)
+		return e.entries(v.ToHashMap().LiteralEntries())
+	case v.IsVector():
+		e.w.WriteByte(tagVector)
+		return e.items(v.ToVector().Items)
+	case v.IsPVector():
+		items := make([]*Value, 0, v.ToPVector().Len())
+		v.ToPVector().Each(func(_ int, item *Value) { items = append(items, item) })
+		e.w.WriteByte(tagPVector)
+		return e.items(items)
+	case v.IsPMap():
+		e.w.WriteByte(tagPMap)
+		return e.entries(v.ToPMap().LiteralEntries())
+	case v.IsAtom():
+		e.w.WriteByte(tagAtom)
+		return e.value(v.ToAtom().Load())
+	case v.IsMutex():
+		e.w.WriteByte(tagMutex)
+	case v.IsRef():
+		e.w.WriteByte(tagRefCell)
+		return e.value(v.ToRef().Load())
+	default:
+		return fmt.Errorf("snapshot: can't save %s %s", v.ValueType, v.PrintStr())
+	}
+
+	return nil
+}
+
+func (e *encoder) items(items []*Value) error {
+	e.uint(uint64(len(items)))
+	for _, item := range items {
+		if err := e.value(item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *encoder) entries(keys []*Value, values []*Value) error {
+	e.uint(uint64(len(keys)))
+	for i := range keys {
+		if err := e.value(keys[i]); err != nil {
+			return err
+		}
+		if err := e.value(values[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type decoder struct {
+	r       *bufio.Reader
+	values  []*Value
+	natives Natives
+}
+
+var errCorrupted = errors.New("snapshot: corrupted image")
+
+func (d *decoder) uint() (uint64, error) {
+	n, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return 0, errCorrupted
+	}
+
+	return n, nil
+}
+
+func (d *decoder) string() (string, error) {
+	length, err := d.uint()
+	if err != nil {
+		return "", err
+	}
+
+	// the length can be anything in a corrupted image, so the buffer only
+	// grows as the data is actually read
+	if length > math.MaxInt64 {
+		return "", errCorrupted
+	}
+	var buf strings.Builder
+	if _, err := io.CopyN(&buf, d.r, int64(length)); err != nil {
+		return "", errCorrupted
+	}
+
+	return buf.String(), nil
+}
+
+// Ids are given in the same order as by the encoder: before reading the
+// contents of containers so they can refer to themselves
+func (d *decoder) register(v *Value) *Value {
+	d.values = append(d.values, v)
+	return v
+}
+
+func (d *decoder) value() (*Value, error) {
+	tag, err := d.r.ReadByte()
+	if err != nil {
+		return nil, errCorrupted
+	}
+
+	switch tag {
+	case tagTrue:
+		return BuildBool(true), nil
+	case tagFalse:
+		return BuildBool(false), nil
+
+	case tagRef:
+		id, err := d.uint()
+		if err != nil {
+			return nil, err
+		}
+		if id >= uint64(len(d.values)) {
+			return nil, errCorrupted
+		}
+		return d.values[id], nil
+
+	case tagSymbol, tagKeyword, tagString, tagNative:
+		// reserve the id, the value is known after reading the name
+		id := len(d.values)
+		d.register(nil)
+
+		s, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+
+		var v *Value
+		switch tag {
+		case tagSymbol:
+			v = BuildSymbol(s)
+		case tagKeyword:
+			v = BuildKeyword(s)
+		case tagString:
+			v = BuildString(s)
+		default:
+			native, found := d.natives[s]
+			if !found {
+				return nil, &MissingNativeError{s}
+			}
+			v = native
+		}
+
+		d.values[id] = v
+		return v, nil
+
+	case tagInteger:
+		id := len(d.values)
+		d.register(nil)
+
+		n, err := binary.ReadVarint(d.r)
+		if err != nil {
+			return nil, errCorrupted
+		}
+		d.values[id] = BuildInteger(int(n))
+		return d.values[id], nil
+
+	case tagEmptyList:
+		return d.register(BuildEmptyList()), nil
+
+	case tagCons:
+		v := d.register(&Value{})
+		car, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		cdr, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		*v = *BuildCons(car, cdr)
+		return v, nil
+
+	case tagHashMap:
+		h := NewHashMap()
+		v := d.register(BuildHashMap(h))
+		var keys, values []*Value
+		err := d.entries(func(key *Value, value *Value) {
+			keys = append(keys, key)
+			values = append(values, value)
+		})
+		// equal keys are only saved for literals
+		*h = *NewHashMapLiteral(keys, values)
+		return v, err
+
+	case tagVector:
+		vector := BuildVector(nil)
+		v := d.register(vector)
+		err := d.items(func(item *Value) { vector.ToVector().Push(item) })
+		return v, err
+
+	case tagPVector:
+		v := d.register(&Value{})
+		result := EmptyPVector()
+		if err := d.items(func(item *Value) { result = result.Conj(item) }); err != nil {
+			return nil, err
+		}
+		*v = *BuildPVector(result)
+		return v, nil
+
+	case tagPMap:
+		v := d.register(&Value{})
+		var keys, values []*Value
+		err := d.entries(func(key *Value, value *Value) {
+			keys = append(keys, key)
+			values = append(values, value)
+		})
+		if err != nil {
+			return nil, err
+		}
+		// equal keys are only saved for literals
+		*v = *BuildPMap(NewPMapLiteral(keys, values))
+		return v, nil
+
+	case tagAtom:
+		a := NewAtom(nil)
+		v := d.register(BuildAtom(a))
+		value, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		a.Store(value)
+		return v, nil
+
+	case tagMutex:
+		return d.register(BuildMutex(&Mutex{})), nil
+
+	case tagRefCell:
+		ref := NewRef(nil)
+		v := d.register(BuildRef(ref))
+		value, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+
+		// refs only change in transactions
+		tx := NewTransaction()
+		tx.Set(ref, value)
+		tx.Commit()
+		return v, nil
+	}
+
+	return nil, errCorrupted
+}
+
+func (d *decoder) items(add func(*Value)) error {
+	count, err := d.uint()
+	if err != nil {
+		return err
+	}
+
+	for i := uint64(0); i < count; i++ {
+		item, err := d.value()
+		if err != nil {
+			return err
+		}
+		add(item)
+	}
+
+	return nil
+}
+
+func (d *decoder) entries(add func(key *Value, value *Value)) error {
+	count, err := d.uint()
+	if err != nil {
+		return err
+	}
+
+	for i := uint64(0); i < count; i++ {
+		key, err := d.value()
+		if err != nil {
+			return err
+		}
+		value, err := d.value()
+		if err != nil {
+			return err
+		}
+		add(key, value)
+	}
+
+	return nil
+}
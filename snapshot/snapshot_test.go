@@ -0,0 +1,137 @@
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"nondv.io/glisp/interpreter"
+	. "nondv.io/glisp/types"
+)
+
+func roundTrip(t *testing.T, b *Bindings) *Bindings {
+	var image bytes.Buffer
+	require.NoError(t, Save(&image, b, NativesFrom(b)))
+
+	restored, err := Load(&image, DefaultNatives())
+	require.NoError(t, err)
+	return restored
+}
+
+func evalPrint(t *testing.T, b *Bindings, code string) string {
+	result, err := interpreter.ReadEval(b, code)
+	require.NoError(t, err, code)
+	return result.PrintStr()
+}
+
+func TestSnapshot(t *testing.T) {
+	b := interpreter.BuildBaseBindings()
+	_, err := interpreter.ReadEval(b, `(load "../lang/core.lisp")`)
+	require.NoError(t, err)
+	_, err = interpreter.ReadEvalAll(b, `
+      (define data (list 1 "two" :three #t (vector 4) {"five" 5} (pvector 6) (pmap :seven 7)))
+      (define counter (atom 10))
+      (define account (ref 20))
+      (define lock (mutex))`)
+	require.NoError(t, err)
+
+	restored := roundTrip(t, b)
+	require.Equal(t, evalPrint(t, b, "data"), evalPrint(t, restored, "data"))
+	require.Equal(t, "(2 3 4)", evalPrint(t, restored, "(mapcar (lambda (x) (+ x 1)) (list 1 2 3))"))
+	require.Equal(t, "6", evalPrint(t, restored, "(reduce 0 + (list 1 2 3))"))
+	require.Equal(t, "11", evalPrint(t, restored, "(swap! counter + 1)"))
+	require.Equal(t, "21", evalPrint(t, restored, "(dosync (alter account + 1))"))
+	require.Equal(t, "#<mutex>", evalPrint(t, restored, "lock"))
+
+	// the original isn't affected
+	require.Equal(t, "10", evalPrint(t, b, "(deref counter)"))
+}
+
+func TestSnapshotSharing(t *testing.T) {
+	b := interpreter.BuildBaseBindings()
+	_, err := interpreter.ReadEvalAll(b, `
+      (define v (vector 1))
+      (define same-v v)
+      (vector-push v v)`)
+	require.NoError(t, err)
+
+	restored := roundTrip(t, b)
+	evalPrint(t, restored, "(vector-set! same-v 0 42)")
+	require.Equal(t, "42", evalPrint(t, restored, "(vector-ref v 0)"))
+	// cycles are preserved too
+	require.Equal(t, "42", evalPrint(t, restored, "(vector-ref (vector-ref v 1) 0)"))
+}
+
+// Literals in saved code still evaluate every key
+func TestSnapshotHashMapLiteral(t *testing.T) {
+	b := interpreter.BuildBaseBindings()
+	_, err := interpreter.ReadEvalAll(b, `
+      (define n 0)
+      (define make (lambda () {(set! n (+ n 1)) :a (set! n (+ n 1)) :b}))`)
+	require.NoError(t, err)
+
+	restored := roundTrip(t, b)
+	require.Equal(t, "{1 :a 2 :b}", evalPrint(t, restored, "(make)"))
+}
+
+func TestSnapshotErrors(t *testing.T) {
+	b := interpreter.BuildBaseBindings()
+	b.DefineSym("sqr", WrapGoFunc(func(n int) int { return n * n }))
+
+	var image bytes.Buffer
+	require.NoError(t, Save(&image, b, NativesFrom(b)))
+
+	_, err := Load(bytes.NewReader(image.Bytes()), DefaultNatives())
+	var missing *MissingNativeError
+	require.True(t, errors.As(err, &missing))
+	require.Equal(t, "sqr", missing.Name)
+	require.EqualError(t, err, "global sqr: snapshot: missing native sqr")
+
+	// unregistered natives can't be saved
+	natives := NativesFrom(b)
+	delete(natives, "sqr")
+	require.EqualError(t, Save(&bytes.Buffer{}, b, natives), "global sqr: snapshot: native fn isn't registered")
+
+	b = NewBindings()
+	b.DefineSym("object", BuildGoObject(&bytes.Buffer{}))
+	require.EqualError(t, Save(&bytes.Buffer{}, b, nil), "global object: snapshot: can't save go object #<go:*bytes.Buffer>")
+
+	_, err = Load(bytes.NewReader([]byte("not an image")), nil)
+	require.ErrorIs(t, err, ErrNotImage)
+
+	_, err = Load(bytes.NewReader([]byte(magic+"\x02\x00")), nil)
+	require.EqualError(t, err, "snapshot: unsupported image version 2 (supported: 1)")
+
+	truncated := image.Bytes()[:image.Len()/2]
+	_, err = Load(bytes.NewReader(truncated), NativesFrom(interpreter.BuildBaseBindings()))
+	require.ErrorIs(t, err, errCorrupted)
+
+	// a global whose name is a symbol with a huge length
+	for _, length := range []string{"\xff\xff\xff\xff\xff\xff\xff\xff\x7f", "\xff\xff\xff\xff\xff\xff\xff\xff\xff\x01"} {
+		_, err = Load(bytes.NewReader([]byte(magic+"\x01\x01\x01"+length)), nil)
+		require.ErrorIs(t, err, errCorrupted)
+	}
+}
+
+func BenchmarkLoad(b *testing.B) {
+	bindings := interpreter.BuildBaseBindings()
+	if _, err := interpreter.ReadEval(bindings, `(load "../lang/core.lisp")`); err != nil {
+		b.Fatal(err)
+	}
+
+	var image bytes.Buffer
+	if err := Save(&image, bindings, NativesFrom(bindings)); err != nil {
+		b.Fatal(err)
+	}
+	natives := DefaultNatives()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Load(bytes.NewReader(image.Bytes()), natives); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package types
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+ * Shared mutable state that's safe to use from several goroutines (including
+ * Go code embedding the interpreter):
+ *
+ *   Atom  - a single value changed atomically
+ *   Mutex - a plain lock
+ *   Ref   - a value changed in transactions together with other refs (stm.go)
+ *
+ * All of them are compared by identity.
+ */
+
+type Atom struct {
+	value atomic.Pointer[Value]
+}
+
+func NewAtom(v *Value) *Atom {
+	a := &Atom{}
+	a.value.Store(v)
+	return a
+}
+
+func (a *Atom) Load() *Value {
+	return a.value.Load()
+}
+
+func (a *Atom) Store(v *Value) {
+	a.value.Store(v)
+}
+
+// Sets the value to new if the current one is Equal to old
+func (a *Atom) CompareAndSwap(old *Value, new *Value) bool {
+	for {
+		current := a.value.Load()
+		if !Equal(current, old) {
+			return false
+		}
+		if a.value.CompareAndSwap(current, new) {
+			return true
+		}
+	}
+}
+
+// Sets the value to f(current value). f may be called several times if other
+// goroutines change the atom meanwhile, so it should have no side effects.
+// Returns the new value
+func (a *Atom) Swap(f func(*Value) (*Value, error)) (*Value, error) {
+	for {
+		current := a.value.Load()
+		new, err := f(current)
+		if err != nil {
+			return nil, err
+		}
+		if a.value.CompareAndSwap(current, new) {
+			return new, nil
+		}
+	}
+}
+
+// Unlike sync.Mutex, waiting for it can be cancelled (see LockContext). The
+// zero value is unlocked
+type Mutex struct {
+	once sync.Once
+	// holds a token while locked
+	token chan struct{}
+}
+
+func (m *Mutex) init() {
+	m.once.Do(func() { m.token = make(chan struct{}, 1) })
+}
+
+func (m *Mutex) Lock() {
+	m.init()
+	m.token <- struct{}{}
+}
+
+// Same as Lock but gives up when ctx is done (unless the mutex is free)
+func (m *Mutex) LockContext(ctx context.Context) error {
+	m.init()
+	select {
+	case m.token <- struct{}{}:
+		return nil
+	default:
+	}
+
+	select {
+	case m.token <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *Mutex) Unlock() {
+	m.init()
+	select {
+	case <-m.token:
+	default:
+		panic("unlock of unlocked mutex")
+	}
+}
+
+func BuildAtom(a *Atom) *Value {
+	return &Value{atomReference, a}
+}
+
+func BuildMutex(m *Mutex) *Value {
+	return &Value{mutexReference, m}
+}
+
+func (v *Value) IsAtom() bool  { return v.ValueType == atomReference }
+func (v *Value) IsMutex() bool { return v.ValueType == mutexReference }
+
+func (v *Value) ToAtom() *Atom {
+	if !v.IsAtom() {
+		panic("Not an atom")
+	}
+
+	return v.Value.(*Atom)
+}
+
+func (v *Value) ToMutex() *Mutex {
+	if !v.IsMutex() {
+		panic("Not a mutex")
+	}
+
+	return v.Value.(*Mutex)
+}
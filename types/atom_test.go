@@ -0,0 +1,133 @@
+package types
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAtom(t *testing.T) {
+	a := NewAtom(BuildInteger(0))
+	value := BuildAtom(a)
+	require.Equal(t, "#<atom 0>", value.PrintStr())
+	require.True(t, Equal(value, BuildAtom(a)))
+	require.False(t, Equal(value, BuildAtom(NewAtom(BuildInteger(0)))))
+
+	// compared with Equal, not by pointer
+	require.True(t, a.CompareAndSwap(BuildInteger(0), BuildInteger(1)))
+	require.False(t, a.CompareAndSwap(BuildInteger(0), BuildInteger(2)))
+	require.Equal(t, 1, a.Load().ToInt())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				a.Swap(func(v *Value) (*Value, error) { return BuildInteger(v.ToInt() + 1), nil })
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, 801, a.Load().ToInt())
+
+	// shared with Go code
+	var fromGo *Atom
+	require.NoError(t, ToGo(value, &fromGo))
+	require.Same(t, a, fromGo)
+	converted, err := FromGo(a)
+	require.NoError(t, err)
+	require.True(t, Equal(value, converted))
+}
+
+func TestDosync(t *testing.T) {
+	from, to := NewRef(BuildInteger(100)), NewRef(BuildInteger(0))
+	require.Equal(t, "#<ref 100>", BuildRef(from).PrintStr())
+
+	transfer := func(tx *Transaction) (*Value, error) {
+		a, err := tx.Get(from)
+		if err != nil {
+			return nil, err
+		}
+		b, err := tx.Get(to)
+		if err != nil {
+			return nil, err
+		}
+
+		tx.Set(from, BuildInteger(a.ToInt()-1))
+		tx.Set(to, BuildInteger(b.ToInt()+1))
+		return BuildEmptyList(), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if _, err := Dosync(transfer); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, 0, from.Load().ToInt())
+	require.Equal(t, 100, to.Load().ToInt())
+}
+
+func TestTransactionConflict(t *testing.T) {
+	r := NewRef(BuildInteger(1))
+
+	tx := NewTransaction()
+	_, err := tx.Get(r)
+	require.NoError(t, err)
+	tx.Set(r, BuildInteger(2))
+
+	// someone else commits first
+	other := NewTransaction()
+	other.Set(r, BuildInteger(10))
+	require.True(t, other.Commit())
+
+	require.False(t, tx.Commit())
+	require.Equal(t, 10, r.Load().ToInt())
+
+	// reading a ref that changed since the first read
+	tx = NewTransaction()
+	tx.Get(r)
+	other = NewTransaction()
+	other.Set(r, BuildInteger(11))
+	other.Commit()
+	_, err = tx.Get(r)
+	require.ErrorIs(t, err, ErrTransactionConflict)
+
+	// reading another ref after a commit changed one read before: the two
+	// values would never have been current together
+	a, b := NewRef(BuildInteger(1)), NewRef(BuildInteger(1))
+	tx = NewTransaction()
+	_, err = tx.Get(a)
+	require.NoError(t, err)
+	other = NewTransaction()
+	other.Set(a, BuildInteger(2))
+	other.Set(b, BuildInteger(2))
+	other.Commit()
+	_, err = tx.Get(b)
+	require.ErrorIs(t, err, ErrTransactionConflict)
+}
+
+func TestMutexLockContext(t *testing.T) {
+	var m Mutex
+	m.Lock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, m.LockContext(ctx), context.Canceled)
+
+	m.Unlock()
+	require.NoError(t, m.LockContext(ctx))
+	m.Unlock()
+	require.Panics(t, m.Unlock)
+}
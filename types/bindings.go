@@ -1,28 +1,208 @@
 package types
 
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Bindings is an environment: a chain of small local frames (created by let
+// and lambda calls) on top of a global frame backed by a persistent map.
+//
+// Local frames are persistent: Assoc and Extend return new bindings and never
+// change the receiver. The global frame is shared by all bindings derived from
+// the same NewBindings call and is modified by Define.
+//
+// Bindings can also carry a Runtime (see WithRuntime), which is inherited by
+// all bindings derived from them.
+//
+// Concurrency: globals are immutable snapshots (persistent maps). Define and
+// Set build a new snapshot sharing most of the old one and swap it in
+// atomically, so any number of goroutines can evaluate code (and redefine
+// globals) with the same bindings. Local frames belong to the evaluation that
+// created them; set! on a local variable shared between goroutines (e.g.
+// captured by spawned code) is a data race, same as mutating a hash map or a
+// vector from several goroutines.
 type Bindings struct {
-	SymbolName string
-	Value      *Value
-	Next       *Bindings
+	frame   *frame
+	global  *globalFrame
+	runtime *Runtime
+}
+
+type frame struct {
+	symbols []*Value
+	values  []*Value
+	parent  *frame
+}
+
+type globalFrame struct {
+	state atomic.Pointer[globalState]
+	// serializes writers so concurrent definitions aren't lost
+	mu sync.Mutex
+}
+
+// Swapped as a whole so forks never mix up globals and the host state
+type globalState struct {
+	vars *PMap
+	host any
 }
 
+func newGlobalFrame(state *globalState) *globalFrame {
+	global := &globalFrame{}
+	global.state.Store(state)
+	return global
+}
+
+func (g *globalFrame) vars() *PMap {
+	return g.state.Load().vars
+}
+
+// Stores the snapshot change returns unless it's nil
+func (g *globalFrame) update(change func(vars *PMap) *PMap) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	state := g.state.Load()
+	vars := change(state.vars)
+	if vars == nil {
+		return false
+	}
+
+	g.state.Store(&globalState{vars, state.host})
+	return true
+}
+
+func NewBindings() *Bindings {
+	return &Bindings{nil, newGlobalFrame(&globalState{EmptyPMap(), nil}), nil}
+}
+
+// Symbols are interned so comparing pointers is enough
 func (b *Bindings) Lookup(sym *Value) (*Value, bool) {
-	name := sym.SymbolName()
-	next := b
-	for next != nil {
-		if name == next.SymbolName {
-			return next.Value, true
+	for f := b.frame; f != nil; f = f.parent {
+		// later symbols in a frame shadow earlier ones
+		for i := len(f.symbols) - 1; i >= 0; i-- {
+			if f.symbols[i] == sym {
+				return f.values[i], true
+			}
 		}
-		next = next.Next
 	}
 
-	return nil, false
+	return b.global.vars().Get(sym)
+}
+
+// Returns the i-th value of the local frame depth levels up from the innermost
+// one. Used by compiled code which resolves local variables in advance
+func (b *Bindings) Local(depth int, i int) *Value {
+	f := b.frame
+	for ; depth > 0; depth-- {
+		f = f.parent
+	}
+
+	return f.values[i]
 }
 
 func (b *Bindings) Assoc(sym *Value, val *Value) *Bindings {
-	return &Bindings{sym.SymbolName(), val, b}
+	return &Bindings{&frame{[]*Value{sym}, []*Value{val}, b.frame}, b.global, b.runtime}
 }
 
 func (b *Bindings) AssocSym(sym string, val *Value) *Bindings {
 	return b.Assoc(BuildSymbol(sym), val)
 }
+
+// Binds several symbols in a single frame (e.g. lambda parameters).
+// The frame takes ownership of the slices
+func (b *Bindings) Extend(syms []*Value, vals []*Value) *Bindings {
+	if len(syms) != len(vals) {
+		panic("Extend: symbols and values have different lengths")
+	}
+
+	return &Bindings{&frame{syms, vals, b.frame}, b.global, b.runtime}
+}
+
+// Creates or replaces a global binding. The change is visible to all
+// bindings sharing the global frame, including ones created before the
+// definition, unless they shadow the symbol with a local binding
+func (b *Bindings) Define(sym *Value, val *Value) {
+	b.global.update(func(vars *PMap) *PMap {
+		return vars.Assoc(sym, val)
+	})
+}
+
+// Changes the nearest existing binding of sym: the innermost local frame
+// binding it or, if there's none, the global one. The change is visible to
+// all bindings sharing that frame. Returns false if sym is unbound
+func (b *Bindings) Set(sym *Value, val *Value) bool {
+	for f := b.frame; f != nil; f = f.parent {
+		for i := len(f.symbols) - 1; i >= 0; i-- {
+			if f.symbols[i] == sym {
+				f.values[i] = val
+				return true
+			}
+		}
+	}
+
+	return b.global.update(func(vars *PMap) *PMap {
+		if _, found := vars.Get(sym); !found {
+			return nil
+		}
+
+		return vars.Assoc(sym, val)
+	})
+}
+
+func (b *Bindings) DefineSym(sym string, val *Value) {
+	b.Define(BuildSymbol(sym), val)
+}
+
+// Copy of the current global bindings
+func (b *Bindings) Globals() map[*Value]*Value {
+	vars := b.global.vars()
+	result := make(map[*Value]*Value, vars.Len())
+	vars.Each(func(sym *Value, val *Value) { result[sym] = val })
+	return result
+}
+
+// Bindings with their own global frame starting as a copy of the current
+// globals (local frames are still shared). Definitions in the fork aren't
+// visible to the original and vice versa, until ReplaceGlobals
+func (b *Bindings) Fork() *Bindings {
+	// snapshots are immutable so they can be shared
+	return &Bindings{b.frame, newGlobalFrame(b.global.state.Load()), b.runtime}
+}
+
+// Atomically replaces all globals (and the host state) with the current ones
+// of from (usually a fork). Evaluations already running keep seeing the
+// globals they look up after the swap; definitions made since the fork are
+// lost
+func (b *Bindings) ReplaceGlobals(from *Bindings) {
+	b.global.mu.Lock()
+	defer b.global.mu.Unlock()
+
+	b.global.state.Store(from.global.state.Load())
+}
+
+// State the host (e.g. the interpreter's module system) keeps alongside the
+// globals: forked and replaced together with them, but invisible to code,
+// Globals and images. nil until UpdateHost
+func (b *Bindings) Host() any {
+	return b.global.state.Load().host
+}
+
+// Replaces the host state with what change returns. change must not modify
+// its argument (forks may share it) and is called with writers serialized
+func (b *Bindings) UpdateHost(change func(host any) any) {
+	b.global.mu.Lock()
+	defer b.global.mu.Unlock()
+
+	state := b.global.state.Load()
+	b.global.state.Store(&globalState{state.vars, change(state.host)})
+}
+
+// Same bindings (sharing frames and globals) with a different runtime
+func (b *Bindings) WithRuntime(runtime *Runtime) *Bindings {
+	return &Bindings{b.frame, b.global, runtime}
+}
+
+// nil unless set with WithRuntime
+func (b *Bindings) Runtime() *Runtime {
+	return b.runtime
+}
@@ -0,0 +1,253 @@
+package types
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindingsFrames(t *testing.T) {
+	global := NewBindings()
+	global.DefineSym("a", BuildInteger(1))
+
+	local := global.Extend(
+		[]*Value{BuildSymbol("b"), BuildSymbol("c")},
+		[]*Value{BuildInteger(2), BuildInteger(3)})
+	inner := local.AssocSym("a", BuildInteger(10))
+
+	requireLookup(t, 1, global, "a")
+	requireLookup(t, 10, inner, "a")
+	requireLookup(t, 2, inner, "b")
+	requireLookup(t, 3, local, "c")
+
+	_, found := global.Lookup(BuildSymbol("b"))
+	require.False(t, found)
+
+	// global frame is shared
+	global.DefineSym("d", BuildInteger(4))
+	requireLookup(t, 4, inner, "d")
+	inner.DefineSym("a", BuildInteger(5))
+	requireLookup(t, 5, global, "a")
+	requireLookup(t, 10, inner, "a")
+}
+
+func TestBindingsFork(t *testing.T) {
+	original := NewBindings()
+	original.DefineSym("a", BuildInteger(1))
+	local := original.AssocSym("x", BuildInteger(7))
+
+	fork := local.Fork()
+	requireLookup(t, 7, fork, "x")
+	fork.DefineSym("a", BuildInteger(2))
+	fork.DefineSym("b", BuildInteger(3))
+	original.DefineSym("c", BuildInteger(4))
+
+	requireLookup(t, 1, original, "a")
+	requireLookup(t, 2, fork, "a")
+	_, found := original.Lookup(BuildSymbol("b"))
+	require.False(t, found)
+	_, found = fork.Lookup(BuildSymbol("c"))
+	require.False(t, found)
+
+	original.ReplaceGlobals(fork)
+	requireLookup(t, 2, local, "a")
+	requireLookup(t, 3, original, "b")
+	_, found = original.Lookup(BuildSymbol("c"))
+	require.False(t, found)
+}
+
+func TestBindingsHost(t *testing.T) {
+	original := NewBindings()
+	require.Nil(t, original.Host())
+
+	original.UpdateHost(func(host any) any { return "a" })
+	original.DefineSym("x", BuildInteger(1))
+	require.Equal(t, "a", original.Host())
+	require.Len(t, original.Globals(), 1)
+
+	fork := original.Fork()
+	fork.UpdateHost(func(host any) any { return host.(string) + "b" })
+	require.Equal(t, "a", original.Host())
+	require.Equal(t, "ab", fork.Host())
+
+	original.ReplaceGlobals(fork)
+	require.Equal(t, "ab", original.Host())
+}
+
+// Run with -race
+func TestBindingsConcurrentDefine(t *testing.T) {
+	b := NewBindings()
+	b.DefineSym("shared", BuildInteger(0))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				b.DefineSym(fmt.Sprintf("var-%d-%d", i, j), BuildInteger(j))
+				b.Set(BuildSymbol("shared"), BuildInteger(j))
+				b.Lookup(BuildSymbol("shared"))
+			}
+		}()
+	}
+	wg.Wait()
+
+	// no definition is lost
+	for i := 0; i < 8; i++ {
+		requireLookup(t, 99, b, fmt.Sprintf("var-%d-99", i))
+	}
+}
+
+func TestBindingsGlobals(t *testing.T) {
+	b := NewBindings()
+	b.DefineSym("a", BuildInteger(1))
+
+	globals := b.Globals()
+	require.Len(t, globals, 1)
+	require.Equal(t, 1, globals[BuildSymbol("a")].ToInt())
+
+	// a copy
+	globals[BuildSymbol("b")] = BuildInteger(2)
+	_, found := b.Lookup(BuildSymbol("b"))
+	require.False(t, found)
+}
+
+func requireLookup(t *testing.T, expected int, b *Bindings, name string) {
+	val, found := b.Lookup(BuildSymbol(name))
+	require.True(t, found)
+	require.Equal(t, expected, val.ToInt())
+}
+
+/*
+ * Comparison with the linked list bindings used before frames
+ */
+
+type chainBindings struct {
+	Symbol *Value
+	Value  *Value
+	Next   *chainBindings
+}
+
+func (b *chainBindings) Lookup(sym *Value) (*Value, bool) {
+	next := b
+	for next != nil {
+		if sym == next.Symbol {
+			return next.Value, true
+		}
+		next = next.Next
+	}
+
+	return nil, false
+}
+
+func (b *chainBindings) Assoc(sym *Value, val *Value) *chainBindings {
+	return &chainBindings{sym, val, b}
+}
+
+var globalCounts = []int{10, 100, 1000}
+
+// Looks up the first global defined, which is the worst case for the chain
+func BenchmarkGlobalLookupChain(b *testing.B) {
+	for _, n := range globalCounts {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			var bindings *chainBindings
+			for i := 0; i < n; i++ {
+				bindings = bindings.Assoc(BuildSymbol(fmt.Sprint("global-", i)), BuildInteger(i))
+			}
+			sym := BuildSymbol("global-0")
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				bindings.Lookup(sym)
+			}
+		})
+	}
+}
+
+func BenchmarkGlobalLookupFrames(b *testing.B) {
+	for _, n := range globalCounts {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			bindings := NewBindings()
+			for i := 0; i < n; i++ {
+				bindings.Define(BuildSymbol(fmt.Sprint("global-", i)), BuildInteger(i))
+			}
+			// a couple of local frames on top, like inside a function call
+			bindings = bindings.AssocSym("x", BuildInteger(1)).AssocSym("y", BuildInteger(2))
+			sym := BuildSymbol("global-0")
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				bindings.Lookup(sym)
+			}
+		})
+	}
+}
+
+func BenchmarkLocalLookupChain(b *testing.B) {
+	var bindings *chainBindings
+	for i := 0; i < 1000; i++ {
+		bindings = bindings.Assoc(BuildSymbol(fmt.Sprint("global-", i)), BuildInteger(i))
+	}
+	bindings = bindings.Assoc(BuildSymbol("x"), BuildInteger(1))
+	sym := BuildSymbol("x")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bindings.Lookup(sym)
+	}
+}
+
+func BenchmarkLocalLookupFrames(b *testing.B) {
+	bindings := NewBindings()
+	for i := 0; i < 1000; i++ {
+		bindings.Define(BuildSymbol(fmt.Sprint("global-", i)), BuildInteger(i))
+	}
+	bindings = bindings.AssocSym("x", BuildInteger(1))
+	sym := BuildSymbol("x")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bindings.Lookup(sym)
+	}
+}
+
+// Globals are looked up through every local frame first
+func BenchmarkLookupDepth(b *testing.B) {
+	for _, depth := range []int{0, 1, 10, 100} {
+		b.Run(fmt.Sprint(depth), func(b *testing.B) {
+			bindings := NewBindings()
+			bindings.DefineSym("global", BuildInteger(0))
+			for i := 0; i < depth; i++ {
+				bindings = bindings.AssocSym(fmt.Sprint("local-", i), BuildInteger(i))
+			}
+			sym := BuildSymbol("global")
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				bindings.Lookup(sym)
+			}
+		})
+	}
+}
+
+// Defining a global doesn't copy the others
+func BenchmarkDefine(b *testing.B) {
+	for _, n := range globalCounts {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			bindings := NewBindings()
+			for i := 0; i < n; i++ {
+				bindings.Define(BuildSymbol(fmt.Sprint("global-", i)), BuildInteger(i))
+			}
+			sym := BuildSymbol("global-0")
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				bindings.Define(sym, BuildInteger(i))
+			}
+		})
+	}
+}
@@ -0,0 +1,708 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+ * Conversions between lisp values and Go values, similar to encoding/json:
+ *
+ *   integer        <-> int, int8...int64, uint...uint64 (range-checked)
+ *   string         <-> string
+ *   #t/#f          <-> bool
+ *   list/vector    <-> slices and arrays
+ *   hash map/pmap  <-> maps (FromGo sorts the keys)
+ *   hash map/alist/plist <-> structs (see StructFormat)
+ *   ()             <-> nil pointers, slices, maps and interfaces
+ *   go object      <-> registered types (see RegisterGoType)
+ *   atom/mutex/ref <-> *Atom, *Mutex, *Ref
+ *   anything       <-> *Value (passed as is)
+ *
+ * interface{} targets get int, string, bool, nil for (), []any for lists
+ * and vectors, map[any]any for maps and the *Value itself for everything else.
+ *
+ * Struct fields are named by `glisp:"name"` tags (`glisp:"-"` skips a field,
+ * `glisp:"name,omitempty"` skips zero values), otherwise by the field name.
+ * Unexported fields are ignored and fields of embedded structs are treated as
+ * fields of the outer struct.
+ */
+
+// How FromGo represents structs. ToGo accepts all of them
+type StructFormat uint8
+
+const (
+	// {"name" value ...}
+	HashStruct StructFormat = iota
+	// (("name" . value) ...)
+	AlistStruct
+	// (:name value ...)
+	PlistStruct
+)
+
+var (
+	valueType = reflect.TypeOf((*Value)(nil))
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+	anyType   = reflect.TypeOf((*any)(nil)).Elem()
+
+	// passed between Go and lisp as is
+	sharedStateTypes = map[reflect.Type]ValueType{
+		reflect.TypeOf((*Atom)(nil)):  atomReference,
+		reflect.TypeOf((*Mutex)(nil)): mutexReference,
+		reflect.TypeOf((*Ref)(nil)):   refReference,
+	}
+)
+
+// Converts a Go value to a lisp value. Structs become hash maps
+func FromGo(v any) (*Value, error) {
+	return FromGoAs(v, HashStruct)
+}
+
+func FromGoAs(v any, format StructFormat) (*Value, error) {
+	if v == nil {
+		return BuildEmptyList(), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if !convertible(rv.Type()) {
+		return nil, errors.New("can't convert " + rv.Type().String())
+	}
+
+	return fromGo(rv, format)
+}
+
+// Stores v in the value target points to, like json.Unmarshal
+func ToGo(v *Value, target any) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("ToGo: target must be a non-nil pointer, got %T", target)
+	}
+	if !convertible(rv.Type().Elem()) {
+		return errors.New("can't convert to " + rv.Type().Elem().String())
+	}
+
+	converted, err := toGo(v, rv.Type().Elem())
+	if err != nil {
+		return err
+	}
+
+	rv.Elem().Set(converted)
+	return nil
+}
+
+func convertible(t reflect.Type) bool {
+	return convertibleType(t, map[reflect.Type]bool{})
+}
+
+// seen handles recursive types
+func convertibleType(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if _, shared := sharedStateTypes[t]; shared || t == valueType || isGoType(t) || seen[t] {
+		return true
+	}
+	seen[t] = true
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.String, reflect.Bool:
+		return true
+	case reflect.Interface:
+		return t.NumMethod() == 0
+	case reflect.Slice, reflect.Array, reflect.Pointer:
+		return convertibleType(t.Elem(), seen)
+	case reflect.Map:
+		return convertibleType(t.Key(), seen) && convertibleType(t.Elem(), seen)
+	case reflect.Struct:
+		for _, field := range structFields(t) {
+			if !convertibleType(t.FieldByIndex(field.index).Type, seen) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+func typeError(v *Value, t reflect.Type) error {
+	return fmt.Errorf("expected %s, got %s %s", t, v.ValueType, v.PrintStr())
+}
+
+// Converts v to a Go value of type t
+func toGo(v *Value, t reflect.Type) (reflect.Value, error) {
+	if t == valueType {
+		return reflect.ValueOf(v), nil
+	}
+
+	if v.IsGoObject() {
+		return goObjectToGo(v, t)
+	}
+
+	if kind, shared := sharedStateTypes[t]; shared {
+		if v.IsEmptyList() {
+			return reflect.Zero(t), nil
+		}
+		if v.ValueType != kind {
+			return reflect.Value{}, typeError(v, t)
+		}
+		return reflect.ValueOf(v.Value), nil
+	}
+
+	if isGoType(t) {
+		switch t.Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice, reflect.Chan, reflect.Func:
+			if v.IsEmptyList() {
+				return reflect.Zero(t), nil
+			}
+		}
+		return reflect.Value{}, typeError(v, t)
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !v.IsInteger() {
+			return reflect.Value{}, typeError(v, t)
+		}
+		result := reflect.New(t).Elem()
+		if result.OverflowInt(int64(v.ToInt())) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", v.ToInt(), t)
+		}
+		result.SetInt(int64(v.ToInt()))
+		return result, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if !v.IsInteger() {
+			return reflect.Value{}, typeError(v, t)
+		}
+		result := reflect.New(t).Elem()
+		if v.ToInt() < 0 || result.OverflowUint(uint64(v.ToInt())) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", v.ToInt(), t)
+		}
+		result.SetUint(uint64(v.ToInt()))
+		return result, nil
+
+	case reflect.String:
+		if !v.IsString() {
+			return reflect.Value{}, typeError(v, t)
+		}
+		return reflect.ValueOf(v.ToStr()).Convert(t), nil
+
+	case reflect.Bool:
+		if !v.IsBool() {
+			return reflect.Value{}, typeError(v, t)
+		}
+		return reflect.ValueOf(v.ToBool()).Convert(t), nil
+
+	case reflect.Interface:
+		result := reflect.New(t).Elem()
+		if natural := naturalGo(v); natural != nil {
+			result.Set(reflect.ValueOf(natural))
+		}
+		return result, nil
+
+	case reflect.Pointer:
+		if v.IsEmptyList() {
+			return reflect.Zero(t), nil
+		}
+		elem, err := toGo(v, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		result := reflect.New(t.Elem())
+		result.Elem().Set(elem)
+		return result, nil
+
+	case reflect.Slice, reflect.Array:
+		items, ok := sequenceItems(v)
+		if !ok {
+			return reflect.Value{}, typeError(v, t)
+		}
+
+		var result reflect.Value
+		if t.Kind() == reflect.Slice {
+			if v.IsEmptyList() {
+				return reflect.Zero(t), nil
+			}
+			result = reflect.MakeSlice(t, len(items), len(items))
+		} else if len(items) != t.Len() {
+			return reflect.Value{}, fmt.Errorf("expected %d elements, got %d", t.Len(), len(items))
+		} else {
+			result = reflect.New(t).Elem()
+		}
+
+		for i, item := range items {
+			converted, err := toGo(item, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("element %d: %w", i, err)
+			}
+			result.Index(i).Set(converted)
+		}
+		return result, nil
+
+	case reflect.Map:
+		if v.IsEmptyList() {
+			return reflect.Zero(t), nil
+		}
+
+		keys, values, ok := mapEntries(v)
+		if !ok {
+			keys, values, ok = alistEntries(v)
+		}
+		if !ok {
+			return reflect.Value{}, typeError(v, t)
+		}
+
+		result := reflect.MakeMapWithSize(t, len(keys))
+		for i := range keys {
+			key, err := toGo(keys[i], t.Key())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("key %s: %w", keys[i].PrintStr(), err)
+			}
+			value, err := toGo(values[i], t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("value of %s: %w", keys[i].PrintStr(), err)
+			}
+			result.SetMapIndex(key, value)
+		}
+		return result, nil
+
+	case reflect.Struct:
+		return toGoStruct(v, t)
+	}
+
+	return reflect.Value{}, typeError(v, t)
+}
+
+func toGoStruct(v *Value, t reflect.Type) (reflect.Value, error) {
+	keys, values, ok := mapEntries(v)
+	if !ok {
+		keys, values, ok = alistEntries(v)
+	}
+	if !ok {
+		keys, values, ok = plistEntries(v)
+	}
+	if !ok {
+		return reflect.Value{}, typeError(v, t)
+	}
+
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		if key.IsString() {
+			names[i] = key.ToStr()
+		} else if key.IsKeyword() {
+			names[i] = key.KeywordName()
+		}
+	}
+
+	result := reflect.New(t).Elem()
+	for _, field := range structFields(t) {
+		// exact match first, then case-insensitive (like encoding/json)
+		found := -1
+		for i, name := range names {
+			if name == field.name {
+				found = i
+				break
+			}
+			if found == -1 && strings.EqualFold(name, field.name) {
+				found = i
+			}
+		}
+		if found == -1 {
+			continue
+		}
+
+		converted, err := toGo(values[found], t.FieldByIndex(field.index).Type)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("field %s: %w", field.name, err)
+		}
+		result.FieldByIndex(field.index).Set(converted)
+	}
+
+	return result, nil
+}
+
+// Go value for interface{} targets. nil for ()
+func naturalGo(v *Value) any {
+	switch {
+	case v.IsEmptyList():
+		return nil
+	case v.IsInteger():
+		return v.ToInt()
+	case v.IsString():
+		return v.ToStr()
+	case v.IsBool():
+		return v.ToBool()
+	}
+
+	if items, ok := sequenceItems(v); ok {
+		result, _ := toGo(BuildVector(items), reflect.SliceOf(anyType))
+		return result.Interface()
+	}
+
+	// lists and maps can't be Go map keys
+	if keys, _, ok := mapEntries(v); ok {
+		for _, key := range keys {
+			if natural := naturalGo(key); natural != nil && !reflect.TypeOf(natural).Comparable() {
+				return v
+			}
+		}
+
+		result, _ := toGo(v, reflect.MapOf(anyType, anyType))
+		return result.Interface()
+	}
+
+	return v
+}
+
+// Elements of proper lists, vectors and persistent vectors
+func sequenceItems(v *Value) ([]*Value, bool) {
+	switch {
+	case v.IsVector():
+		return v.ToVector().Items, true
+	case v.IsPVector():
+		items := make([]*Value, 0, v.ToPVector().Len())
+		v.ToPVector().Each(func(_ int, item *Value) { items = append(items, item) })
+		return items, true
+	case v.IsList():
+		var items []*Value
+		for iter := v; !iter.IsEmptyList(); iter = iter.Cdr() {
+			items = append(items, iter.Car())
+		}
+		return items, true
+	}
+
+	return nil, false
+}
+
+func mapEntries(v *Value) ([]*Value, []*Value, bool) {
+	switch {
+	case v.IsHashMap():
+		return v.ToHashMap().Keys(), v.ToHashMap().Values(), true
+	case v.IsPMap():
+		return v.ToPMap().Keys(), v.ToPMap().Values(), true
+	}
+
+	return nil, nil, false
+}
+
+// ((key . value) ...). Only the first occurrence of a key counts
+func alistEntries(v *Value) ([]*Value, []*Value, bool) {
+	if !v.IsList() {
+		return nil, nil, false
+	}
+
+	var keys, values []*Value
+	for iter := v; !iter.IsEmptyList(); iter = iter.Cdr() {
+		pair := iter.Car()
+		if !pair.IsCons() {
+			return nil, nil, false
+		}
+
+		duplicate := false
+		for _, key := range keys {
+			duplicate = duplicate || Equal(key, pair.Car())
+		}
+		if !duplicate {
+			keys = append(keys, pair.Car())
+			values = append(values, pair.Cdr())
+		}
+	}
+
+	return keys, values, true
+}
+
+// (:key value ...)
+func plistEntries(v *Value) ([]*Value, []*Value, bool) {
+	if !v.IsList() || v.ListLength()%2 != 0 {
+		return nil, nil, false
+	}
+
+	var keys, values []*Value
+	for iter := v; !iter.IsEmptyList(); iter = iter.Cdr().Cdr() {
+		if !iter.Car().IsKeyword() {
+			return nil, nil, false
+		}
+		keys = append(keys, iter.Car())
+		values = append(values, iter.Cdr().Car())
+	}
+
+	return keys, values, true
+}
+
+// Converts a Go value back into a lisp value
+func fromGo(v reflect.Value, format StructFormat) (*Value, error) {
+	c := &fromGoConverter{format, make(map[goReference]bool)}
+	return c.convert(v)
+}
+
+type fromGoConverter struct {
+	format StructFormat
+	// pointers, maps and slices being converted. Seeing one of them again
+	// means the value refers to itself
+	converting map[goReference]bool
+}
+
+type goReference struct {
+	ptr uintptr
+	len int
+	typ reflect.Type
+}
+
+func (c *fromGoConverter) convert(v reflect.Value) (*Value, error) {
+	if v.Type() == valueType {
+		if v.IsNil() {
+			return BuildEmptyList(), nil
+		}
+		return v.Interface().(*Value), nil
+	}
+
+	if isGoType(v.Type()) {
+		return goObjectFromGo(v), nil
+	}
+
+	if kind, shared := sharedStateTypes[v.Type()]; shared {
+		if v.IsNil() {
+			return BuildEmptyList(), nil
+		}
+		return &Value{kind, v.Interface()}, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := v.Int()
+		if int64(int(n)) != n {
+			return nil, fmt.Errorf("%d overflows int", n)
+		}
+		return BuildInteger(int(n)), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n := v.Uint()
+		if n > uint64(^uint(0)>>1) {
+			return nil, fmt.Errorf("%s overflows int", strconv.FormatUint(n, 10))
+		}
+		return BuildInteger(int(n)), nil
+
+	case reflect.String:
+		return BuildString(v.String()), nil
+
+	case reflect.Bool:
+		return BuildBool(v.Bool()), nil
+
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return BuildEmptyList(), nil
+		}
+		if !convertible(v.Elem().Type()) {
+			return nil, fmt.Errorf("can't convert %s", v.Elem().Type())
+		}
+		if v.Kind() == reflect.Pointer {
+			done, err := c.enter(v)
+			if err != nil {
+				return nil, err
+			}
+			defer done()
+		}
+		return c.convert(v.Elem())
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Len() > 0 {
+			done, err := c.enter(v)
+			if err != nil {
+				return nil, err
+			}
+			defer done()
+		}
+
+		result := BuildEmptyList()
+		for i := v.Len() - 1; i >= 0; i-- {
+			item, err := c.convert(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			result = BuildCons(item, result)
+		}
+		return result, nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			return BuildEmptyList(), nil
+		}
+		done, err := c.enter(v)
+		if err != nil {
+			return nil, err
+		}
+		defer done()
+
+		// sorted so the hash map's order doesn't depend on Go's map order
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return goKeyLess(keys[i], keys[j]) })
+
+		result := NewHashMap()
+		for _, goKey := range keys {
+			key, err := c.convert(goKey)
+			if err != nil {
+				return nil, err
+			}
+			value, err := c.convert(v.MapIndex(goKey))
+			if err != nil {
+				return nil, err
+			}
+			result.Set(key, value)
+		}
+		return BuildHashMap(result), nil
+
+	case reflect.Struct:
+		return c.convertStruct(v)
+	}
+
+	return nil, fmt.Errorf("can't convert %s", v.Type())
+}
+
+func (c *fromGoConverter) convertStruct(v reflect.Value) (*Value, error) {
+	var keys, values []*Value
+	for _, field := range structFields(v.Type()) {
+		fieldValue := v.FieldByIndex(field.index)
+		if field.omitEmpty && fieldValue.IsZero() {
+			continue
+		}
+
+		converted, err := c.convert(fieldValue)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.name, err)
+		}
+
+		if c.format == PlistStruct {
+			keys = append(keys, BuildKeyword(field.name))
+		} else {
+			keys = append(keys, BuildString(field.name))
+		}
+		values = append(values, converted)
+	}
+
+	switch c.format {
+	case AlistStruct:
+		result := BuildEmptyList()
+		for i := len(keys) - 1; i >= 0; i-- {
+			result = BuildCons(BuildCons(keys[i], values[i]), result)
+		}
+		return result, nil
+	case PlistStruct:
+		result := BuildEmptyList()
+		for i := len(keys) - 1; i >= 0; i-- {
+			result = BuildCons(keys[i], BuildCons(values[i], result))
+		}
+		return result, nil
+	}
+
+	result := NewHashMap()
+	for i := range keys {
+		result.Set(keys[i], values[i])
+	}
+	return BuildHashMap(result), nil
+}
+
+// Marks v as being converted. The returned function unmarks it
+func (c *fromGoConverter) enter(v reflect.Value) (func(), error) {
+	ref := goReference{v.Pointer(), 0, v.Type()}
+	if v.Kind() == reflect.Slice {
+		ref.len = v.Len()
+	}
+
+	if c.converting[ref] {
+		return nil, fmt.Errorf("can't convert %s: it refers to itself", v.Type())
+	}
+
+	c.converting[ref] = true
+	return func() { delete(c.converting, ref) }, nil
+}
+
+// Orders Go map keys: numbers, strings and booleans by value, anything else
+// by its printed representation
+func goKeyLess(a, b reflect.Value) bool {
+	if a.Kind() == reflect.Interface {
+		a = a.Elem()
+	}
+	if b.Kind() == reflect.Interface {
+		b = b.Elem()
+	}
+	if !a.IsValid() || !b.IsValid() {
+		return !a.IsValid() && b.IsValid()
+	}
+
+	if a.Kind() == b.Kind() {
+		switch a.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return a.Int() < b.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return a.Uint() < b.Uint()
+		case reflect.String:
+			return a.String() < b.String()
+		case reflect.Bool:
+			return !a.Bool() && b.Bool()
+		}
+	}
+
+	return fmt.Sprintf("%T %v", a.Interface(), a.Interface()) < fmt.Sprintf("%T %v", b.Interface(), b.Interface())
+}
+
+type structField struct {
+	index     []int
+	name      string
+	omitEmpty bool
+}
+
+// reflect.Type -> []structField
+var structFieldsCache sync.Map
+
+func structFields(t reflect.Type) []structField {
+	if cached, found := structFieldsCache.Load(t); found {
+		return cached.([]structField)
+	}
+
+	var fields, embedded []structField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("glisp")
+		if tag == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+
+		// fields of embedded structs come after (and lose to) the outer ones
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && name == "" {
+			for _, inner := range structFields(field.Type) {
+				inner.index = append([]int{i}, inner.index...)
+				embedded = append(embedded, inner)
+			}
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, structField{[]int{i}, name, options == "omitempty"})
+	}
+
+	for _, inner := range embedded {
+		taken := false
+		for _, field := range fields {
+			taken = taken || field.name == inner.name
+		}
+		if !taken {
+			fields = append(fields, inner)
+		}
+	}
+
+	structFieldsCache.Store(t, fields)
+	return fields
+}
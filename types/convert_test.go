@@ -0,0 +1,178 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testAddress struct {
+	City string `glisp:"city"`
+}
+
+type testBase struct {
+	ID int `glisp:"id"`
+}
+
+type testPerson struct {
+	testBase
+	Name     string            `glisp:"name"`
+	Age      int               `glisp:"age,omitempty"`
+	Tags     []string          `glisp:"tags"`
+	Address  *testAddress      `glisp:"address"`
+	Extra    map[string]string `glisp:"extra,omitempty"`
+	Ignored  string            `glisp:"-"`
+	Untagged bool
+	private  int
+}
+
+func TestFromGo(t *testing.T) {
+	result, err := FromGo([]any{1, "a", true, nil, []int{2, 3}})
+	require.NoError(t, err)
+	require.Equal(t, `(1 "a" #t () (2 3))`, result.PrintStr())
+
+	person := testPerson{
+		testBase: testBase{ID: 7},
+		Name:     "Ann",
+		Tags:     []string{"x"},
+		Ignored:  "nope",
+		private:  1,
+	}
+
+	result, err = FromGoAs(person, AlistStruct)
+	require.NoError(t, err)
+	require.Equal(t, `(("name" . "Ann") ("tags" "x") ("address") ("Untagged" . #f) ("id" . 7))`, result.PrintStr())
+
+	result, err = FromGoAs(&person, PlistStruct)
+	require.NoError(t, err)
+	require.Equal(t, `(:name "Ann" :tags ("x") :address () :Untagged #f :id 7)`, result.PrintStr())
+
+	result, err = FromGo(person)
+	require.NoError(t, err)
+	require.True(t, result.IsHashMap())
+	name, found := result.ToHashMap().Get(BuildString("name"))
+	require.True(t, found)
+	require.Equal(t, `"Ann"`, name.PrintStr())
+	_, found = result.ToHashMap().Get(BuildString("age"))
+	require.False(t, found)
+
+	_, err = FromGo(func() {})
+	require.EqualError(t, err, "can't convert func()")
+}
+
+type testNode struct {
+	Value int       `glisp:"value"`
+	Next  *testNode `glisp:"next"`
+}
+
+func TestFromGoCycles(t *testing.T) {
+	node := &testNode{Value: 1}
+	node.Next = node
+	_, err := FromGo(node)
+	require.EqualError(t, err, "field next: can't convert *types.testNode: it refers to itself")
+
+	items := []any{1, nil}
+	items[1] = items
+	_, err = FromGo(items)
+	require.EqualError(t, err, "can't convert []interface {}: it refers to itself")
+
+	m := map[string]any{}
+	m["self"] = m
+	_, err = FromGo(m)
+	require.EqualError(t, err, "can't convert map[string]interface {}: it refers to itself")
+
+	// the same value twice isn't a cycle
+	shared := &testNode{Value: 2}
+	result, err := FromGoAs([]*testNode{shared, shared}, PlistStruct)
+	require.NoError(t, err)
+	require.Equal(t, "((:value 2 :next ()) (:value 2 :next ()))", result.PrintStr())
+}
+
+func TestFromGoMapOrder(t *testing.T) {
+	ints := map[int]string{}
+	for i := 20; i > 0; i-- {
+		ints[i] = "x"
+	}
+	result, err := FromGo(ints)
+	require.NoError(t, err)
+	for i, key := range result.ToHashMap().Keys() {
+		require.Equal(t, i+1, key.ToInt())
+	}
+
+	result, err = FromGo(map[string]int{"b": 2, "c": 3, "a": 1})
+	require.NoError(t, err)
+	require.Equal(t, `{"a" 1 "b" 2 "c" 3}`, result.PrintStr())
+}
+
+func TestToGo(t *testing.T) {
+	var n int8
+	require.NoError(t, ToGo(BuildInteger(5), &n))
+	require.Equal(t, int8(5), n)
+	require.EqualError(t, ToGo(BuildInteger(500), &n), "500 overflows int8")
+
+	var items []any
+	require.NoError(t, ToGo(BuildCons(BuildInteger(1), BuildCons(BuildString("a"), BuildEmptyList())), &items))
+	require.Equal(t, []any{1, "a"}, items)
+
+	require.EqualError(t, ToGo(BuildInteger(1), n), "ToGo: target must be a non-nil pointer, got int8")
+}
+
+func TestToGoStruct(t *testing.T) {
+	pair := func(key string, value *Value) *Value { return BuildCons(BuildString(key), value) }
+	address := NewHashMap()
+	address.Set(BuildString("city"), BuildString("Paris"))
+	extra := BuildCons(pair("k", BuildString("v")), BuildEmptyList())
+
+	alist := BuildEmptyList()
+	for _, p := range []*Value{
+		pair("unknown", BuildInteger(0)),
+		pair("untagged", BuildBool(true)),
+		pair("extra", extra),
+		pair("address", BuildHashMap(address)),
+		pair("age", BuildInteger(30)),
+		pair("NAME", BuildString("Ann")),
+		pair("id", BuildInteger(7)),
+	} {
+		alist = BuildCons(p, alist)
+	}
+
+	var person testPerson
+	require.NoError(t, ToGo(alist, &person))
+	require.Equal(t, testPerson{
+		testBase: testBase{ID: 7},
+		Name:     "Ann",
+		Age:      30,
+		Address:  &testAddress{City: "Paris"},
+		Extra:    map[string]string{"k": "v"},
+		Untagged: true,
+	}, person)
+
+	plist := BuildCons(BuildKeyword("name"), BuildCons(BuildString("Bob"), BuildEmptyList()))
+	person = testPerson{}
+	require.NoError(t, ToGo(plist, &person))
+	require.Equal(t, "Bob", person.Name)
+
+	var empty testPerson
+	require.NoError(t, ToGo(BuildEmptyList(), &empty))
+	require.Equal(t, testPerson{}, empty)
+
+	bad := BuildCons(pair("age", BuildString("old")), BuildEmptyList())
+	require.EqualError(t, ToGo(bad, &person), `field age: expected int, got string "old"`)
+}
+
+func TestGoRoundTrip(t *testing.T) {
+	type node struct {
+		Value int   `glisp:"value"`
+		Next  *node `glisp:"next"`
+	}
+
+	list := &node{1, &node{2, nil}}
+	for _, format := range []StructFormat{HashStruct, AlistStruct, PlistStruct} {
+		value, err := FromGoAs(list, format)
+		require.NoError(t, err)
+
+		var decoded *node
+		require.NoError(t, ToGo(value, &decoded))
+		require.Equal(t, list, decoded)
+	}
+}
@@ -0,0 +1,105 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Turns a Go function into a native that evaluates its arguments and converts
+// them to the parameter types (variadic functions included). Results are
+// converted back: no results give (), one result is returned as is, several
+// are returned as a list. A trailing error result is returned as the error.
+//
+// Panics if fn isn't a function or uses types that can't be converted
+func WrapGoFunc(fn any) *Value {
+	fnValue := reflect.ValueOf(fn)
+	if fnValue.Kind() != reflect.Func {
+		panic(fmt.Sprintf("WrapGoFunc: not a function: %T", fn))
+	}
+	fnType := fnValue.Type()
+
+	for i := 0; i < fnType.NumIn(); i++ {
+		paramType := fnType.In(i)
+		if fnType.IsVariadic() && i == fnType.NumIn()-1 {
+			paramType = paramType.Elem()
+		}
+		if !convertible(paramType) {
+			panic("WrapGoFunc: unsupported parameter type " + paramType.String())
+		}
+	}
+
+	returnsError := fnType.NumOut() > 0 && fnType.Out(fnType.NumOut()-1) == errorType
+	results := fnType.NumOut()
+	if returnsError {
+		results--
+	}
+	for i := 0; i < results; i++ {
+		if !convertible(fnType.Out(i)) {
+			panic("WrapGoFunc: unsupported result type " + fnType.Out(i).String())
+		}
+	}
+
+	return BuildApplicativeFn(func(bindings *Bindings, args *Value) (*Value, error) {
+		in, err := goArgs(fnType, args)
+		if err != nil {
+			return nil, err
+		}
+
+		out := fnValue.Call(in)
+		if returnsError && !out[len(out)-1].IsNil() {
+			return nil, out[len(out)-1].Interface().(error)
+		}
+
+		values := make([]*Value, results)
+		for i := range values {
+			values[i], err = fromGo(out[i], HashStruct)
+			if err != nil {
+				return nil, fmt.Errorf("result %d: %w", i, err)
+			}
+		}
+
+		switch results {
+		case 0:
+			return BuildEmptyList(), nil
+		case 1:
+			return values[0], nil
+		}
+
+		list := BuildEmptyList()
+		for i := len(values) - 1; i >= 0; i-- {
+			list = BuildCons(values[i], list)
+		}
+		return list, nil
+	})
+}
+
+func goArgs(fnType reflect.Type, args *Value) ([]reflect.Value, error) {
+	count := args.ListLength()
+	required := fnType.NumIn()
+	if fnType.IsVariadic() {
+		required--
+		if count < required {
+			return nil, fmt.Errorf("expected at least %d arguments, got %d", required, count)
+		}
+	} else if count != required {
+		return nil, fmt.Errorf("expected %d arguments, got %d", required, count)
+	}
+
+	in := make([]reflect.Value, 0, count)
+	for i := 0; !args.IsEmptyList(); i, args = i+1, args.Cdr() {
+		var paramType reflect.Type
+		if i < required {
+			paramType = fnType.In(i)
+		} else {
+			paramType = fnType.In(required).Elem()
+		}
+
+		arg, err := toGo(args.Car(), paramType)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i, err)
+		}
+		in = append(in, arg)
+	}
+
+	return in, nil
+}
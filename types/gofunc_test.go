@@ -0,0 +1,104 @@
+package types
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func callWrapped(fn any, args ...*Value) (*Value, error) {
+	list := BuildEmptyList()
+	for i := len(args) - 1; i >= 0; i-- {
+		list = BuildCons(args[i], list)
+	}
+
+	return WrapGoFunc(fn).NativeFn()(NewBindings(), list)
+}
+
+func TestWrapGoFunc(t *testing.T) {
+	result, err := callWrapped(func(n int, s string) (bool, error) { return len(s) == n, nil },
+		BuildInteger(2), BuildString("ab"))
+	require.NoError(t, err)
+	require.Equal(t, "#t", result.PrintStr())
+
+	result, err = callWrapped(func() {})
+	require.NoError(t, err)
+	require.Equal(t, "()", result.PrintStr())
+
+	result, err = callWrapped(func(a, b int) (int, int) { return b, a }, BuildInteger(1), BuildInteger(2))
+	require.NoError(t, err)
+	require.Equal(t, "(2 1)", result.PrintStr())
+
+	_, err = callWrapped(func() error { return errors.New("boom") })
+	require.EqualError(t, err, "boom")
+
+	result, err = callWrapped(func(v *Value) *Value { return v }, BuildSymbol("sym"))
+	require.NoError(t, err)
+	require.Equal(t, "sym", result.PrintStr())
+}
+
+func TestWrapGoFuncVariadic(t *testing.T) {
+	sum := func(prefix string, ns ...int) string {
+		total := 0
+		for _, n := range ns {
+			total += n
+		}
+		return prefix + string(rune('0'+total))
+	}
+
+	result, err := callWrapped(sum, BuildString("="))
+	require.NoError(t, err)
+	require.Equal(t, `"=0"`, result.PrintStr())
+
+	result, err = callWrapped(sum, BuildString("="), BuildInteger(2), BuildInteger(3))
+	require.NoError(t, err)
+	require.Equal(t, `"=5"`, result.PrintStr())
+
+	_, err = callWrapped(sum)
+	require.EqualError(t, err, "expected at least 1 arguments, got 0")
+
+	_, err = callWrapped(sum, BuildString("="), BuildInteger(2), BuildString("3"))
+	require.EqualError(t, err, `argument 2: expected int, got string "3"`)
+}
+
+func TestWrapGoFuncCollections(t *testing.T) {
+	result, err := callWrapped(func(ns []int) []string {
+		return []string{"len", string(rune('0' + len(ns)))}
+	}, BuildVector([]*Value{BuildInteger(1), BuildInteger(2)}))
+	require.NoError(t, err)
+	require.Equal(t, `("len" "2")`, result.PrintStr())
+
+	h := NewHashMap()
+	h.Set(BuildString("a"), BuildInteger(1))
+	result, err = callWrapped(func(m map[string]int) map[string]int {
+		m["b"] = m["a"] + 1
+		return m
+	}, BuildHashMap(h))
+	require.NoError(t, err)
+	require.Equal(t, 2, result.ToHashMap().Len())
+
+	result, err = callWrapped(func(v any) any { return v },
+		BuildCons(BuildInteger(1), BuildCons(BuildString("x"), BuildEmptyList())))
+	require.NoError(t, err)
+	require.Equal(t, `(1 "x")`, result.PrintStr())
+
+	_, err = callWrapped(func(ns []int) {}, BuildCons(BuildString("x"), BuildEmptyList()))
+	require.EqualError(t, err, `argument 0: element 0: expected int, got string "x"`)
+}
+
+func TestWrapGoFuncErrors(t *testing.T) {
+	_, err := callWrapped(func(a int, b string) {}, BuildInteger(1))
+	require.EqualError(t, err, "expected 2 arguments, got 1")
+
+	_, err = callWrapped(func(a int, b string) {}, BuildInteger(1), BuildInteger(2))
+	require.EqualError(t, err, "argument 1: expected string, got integer 2")
+
+	_, err = callWrapped(func(b uint8) {}, BuildInteger(256))
+	require.EqualError(t, err, "argument 0: 256 overflows uint8")
+
+	require.Panics(t, func() { WrapGoFunc(1) })
+	require.Panics(t, func() { WrapGoFunc(nil) })
+	require.Panics(t, func() { WrapGoFunc(func(float64) {}) })
+	require.Panics(t, func() { WrapGoFunc(func() chan int { return nil }) })
+}
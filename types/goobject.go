@@ -0,0 +1,157 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+/*
+ * Go objects are opaque handles for Go values lisp can't (or shouldn't)
+ * convert to data: database handles, response writers and so on. Lisp code
+ * can only pass them around and compare them; everything else goes through
+ * natives.
+ *
+ * Registered types (see RegisterGoType) are wrapped and unwrapped
+ * automatically by WrapGoFunc, FromGo and ToGo.
+ */
+
+type GoObject struct {
+	Object any
+}
+
+func BuildGoObject(object any) *Value {
+	return &Value{goObjectReference, &GoObject{object}}
+}
+
+func (v *Value) IsGoObject() bool { return v.ValueType == goObjectReference }
+
+func (v *Value) ToGoObject() any {
+	if !v.IsGoObject() {
+		panic("Not a go object")
+	}
+
+	return v.Value.(*GoObject).Object
+}
+
+// Same object: handles of the same pointer, map, channel or function are
+// equal, anything else only equals its own handle. Other values aren't
+// compared with == since that panics for some of them (e.g. structs holding
+// slices in interface fields)
+func goObjectsEqual(a *Value, b *Value) bool {
+	if a.Value == b.Value {
+		return true
+	}
+
+	x, y := reflect.ValueOf(a.ToGoObject()), reflect.ValueOf(b.ToGoObject())
+	if !x.IsValid() || !y.IsValid() {
+		return x.IsValid() == y.IsValid()
+	}
+
+	return x.Type() == y.Type() && isReference(x.Type()) && x.Pointer() == y.Pointer()
+}
+
+func isReference(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return true
+	}
+
+	return false
+}
+
+// Consistent with goObjectsEqual: references are hashed by their type and
+// address, anything else by its handle
+func goObjectHashKey(v *Value) string {
+	object := reflect.ValueOf(v.ToGoObject())
+	if !object.IsValid() {
+		return "nil"
+	}
+	if !isReference(object.Type()) {
+		return fmt.Sprintf("%p", v.Value)
+	}
+
+	return fmt.Sprintf("%s %x", object.Type(), object.Pointer())
+}
+
+// Types passed between Go and lisp as go objects. reflect.Type -> string
+// (the prefix of its natives)
+var goTypes sync.Map
+
+func isGoType(t reflect.Type) bool {
+	_, found := goTypes.Load(t)
+	return found
+}
+
+// Registers the type of *sample (so interfaces can be registered too) as a Go
+// object type and returns natives for its methods. Every method must be a
+// function whose first parameter is that type; it's named PREFIX/NAME:
+//
+//	natives, err := types.RegisterGoType("http", (*http.ResponseWriter)(nil), map[string]any{
+//		"write": func(w http.ResponseWriter, s string) error {
+//			_, err := io.WriteString(w, s)
+//			return err
+//		},
+//	})
+//	// (http/write w "hello")
+//
+// Registrations are process-wide. Registering a type again is fine as long as
+// the prefix is the same
+func RegisterGoType(prefix string, sample any, methods map[string]any) (map[string]*Value, error) {
+	sampleType := reflect.TypeOf(sample)
+	if sampleType == nil || sampleType.Kind() != reflect.Pointer {
+		return nil, fmt.Errorf("RegisterGoType: sample must be a pointer to the type, got %T", sample)
+	}
+	t := sampleType.Elem()
+
+	for name, method := range methods {
+		methodType := reflect.TypeOf(method)
+		if methodType == nil || methodType.Kind() != reflect.Func || methodType.NumIn() == 0 || methodType.In(0) != t {
+			return nil, fmt.Errorf("RegisterGoType: %s/%s must be a function taking %s first", prefix, name, t)
+		}
+	}
+
+	if registered, loaded := goTypes.LoadOrStore(t, prefix); loaded && registered != prefix {
+		return nil, fmt.Errorf("RegisterGoType: %s is registered as %s already", t, registered)
+	}
+
+	// after registering, so WrapGoFunc accepts t
+	natives := make(map[string]*Value, len(methods))
+	for name, method := range methods {
+		natives[prefix+"/"+name] = WrapGoFunc(method)
+	}
+
+	return natives, nil
+}
+
+// Go object handle for v if its type is registered
+func goObjectFromGo(v reflect.Value) *Value {
+	if (v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer) && v.IsNil() {
+		return BuildEmptyList()
+	}
+
+	return BuildGoObject(v.Interface())
+}
+
+// The object wrapped by v if it fits t
+func goObjectToGo(v *Value, t reflect.Type) (reflect.Value, error) {
+	object := reflect.ValueOf(v.ToGoObject())
+	if !object.IsValid() {
+		return reflect.Zero(t), nil
+	}
+	if !object.Type().AssignableTo(t) {
+		return reflect.Value{}, fmt.Errorf("expected %s, got %s", t, v.PrintStr())
+	}
+
+	result := reflect.New(t).Elem()
+	result.Set(object)
+	return result, nil
+}
+
+func goObjectPrintStr(v *Value) string {
+	if v.ToGoObject() == nil {
+		return "#<go:nil>"
+	}
+
+	return fmt.Sprintf("#<go:%T>", v.ToGoObject())
+}
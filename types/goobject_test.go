@@ -0,0 +1,114 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testCounter struct {
+	n int
+}
+
+type testGreeter interface {
+	Greet() string
+}
+
+type testEnglish struct{}
+
+func (testEnglish) Greet() string { return "hello" }
+
+func TestGoObject(t *testing.T) {
+	counter := &testCounter{}
+	a, b := BuildGoObject(counter), BuildGoObject(counter)
+
+	require.Equal(t, "#<go:*types.testCounter>", a.PrintStr())
+	require.Equal(t, "#<go:nil>", BuildGoObject(nil).PrintStr())
+	require.True(t, Equal(a, b))
+	require.False(t, Equal(a, BuildGoObject(&testCounter{})))
+	require.Equal(t, Hash(a), Hash(b))
+
+	// not comparable, so only the handle itself
+	slice := BuildGoObject([]int{1})
+	require.True(t, Equal(slice, slice))
+	require.False(t, Equal(slice, BuildGoObject([]int{1})))
+
+	// values that aren't references aren't compared either, == could panic
+	type holder struct{ x any }
+	value := BuildGoObject(holder{[]int{1}})
+	require.True(t, Equal(value, value))
+	require.False(t, Equal(value, BuildGoObject(holder{[]int{1}})))
+	require.False(t, Equal(BuildGoObject(1), BuildGoObject(1)))
+	require.NotEqual(t, Hash(BuildGoObject(1)), Hash(BuildGoObject(1)))
+	require.True(t, Equal(BuildGoObject(nil), BuildGoObject(nil)))
+
+	m := NewHashMap()
+	m.Set(a, BuildInteger(1))
+	found, ok := m.Get(b)
+	require.True(t, ok)
+	require.Equal(t, 1, found.ToInt())
+}
+
+func TestRegisterGoType(t *testing.T) {
+	natives, err := RegisterGoType("counter", (**testCounter)(nil), map[string]any{
+		"inc": func(c *testCounter, by int) int {
+			c.n += by
+			return c.n
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, natives, 1)
+
+	counter := &testCounter{}
+	value, err := FromGo(counter)
+	require.NoError(t, err)
+	require.True(t, value.IsGoObject())
+
+	result, err := natives["counter/inc"].NativeFn()(NewBindings(), BuildCons(value, BuildCons(BuildInteger(2), BuildEmptyList())))
+	require.NoError(t, err)
+	require.Equal(t, 2, result.ToInt())
+	require.Equal(t, 2, counter.n)
+
+	_, err = natives["counter/inc"].NativeFn()(NewBindings(), BuildCons(BuildInteger(1), BuildCons(BuildInteger(2), BuildEmptyList())))
+	require.EqualError(t, err, "argument 0: expected *types.testCounter, got integer 1")
+
+	var back *testCounter
+	require.NoError(t, ToGo(value, &back))
+	require.Same(t, counter, back)
+
+	var nothing *testCounter
+	value, err = FromGo(nothing)
+	require.NoError(t, err)
+	require.Equal(t, "()", value.PrintStr())
+
+	_, err = RegisterGoType("counter", testCounter{}, nil)
+	require.EqualError(t, err, "RegisterGoType: sample must be a pointer to the type, got types.testCounter")
+	_, err = RegisterGoType("counter", (**testCounter)(nil), map[string]any{"bad": func(n int) {}})
+	require.EqualError(t, err, "RegisterGoType: counter/bad must be a function taking *types.testCounter first")
+
+	// registering again is fine unless the prefix changes
+	_, err = RegisterGoType("counter", (**testCounter)(nil), nil)
+	require.NoError(t, err)
+	_, err = RegisterGoType("other", (**testCounter)(nil), nil)
+	require.EqualError(t, err, "RegisterGoType: *types.testCounter is registered as counter already")
+}
+
+func TestRegisterGoInterface(t *testing.T) {
+	natives, err := RegisterGoType("greeter", (*testGreeter)(nil), map[string]any{
+		"greet": func(g testGreeter, name string) string { return g.Greet() + ", " + name },
+	})
+	require.NoError(t, err)
+
+	greet := WrapGoFunc(func() testGreeter { return testEnglish{} })
+	greeter, err := greet.NativeFn()(NewBindings(), BuildEmptyList())
+	require.NoError(t, err)
+	require.Equal(t, "#<go:types.testEnglish>", greeter.PrintStr())
+
+	result, err := natives["greeter/greet"].NativeFn()(NewBindings(), BuildCons(greeter, BuildCons(BuildString("Ann"), BuildEmptyList())))
+	require.NoError(t, err)
+	require.Equal(t, `"hello, Ann"`, result.PrintStr())
+
+	_, err = natives["greeter/greet"].NativeFn()(NewBindings(), BuildCons(BuildGoObject(&testCounter{}), BuildCons(BuildString("Ann"), BuildEmptyList())))
+	require.True(t, strings.HasPrefix(err.Error(), "argument 0: expected types.testGreeter"))
+}
@@ -0,0 +1,225 @@
+package types
+
+import (
+	"container/list"
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+	"reflect"
+)
+
+// HashMap is a mutable hash table keyed by Equal semantics, except that
+// mutable keys (hash maps and vectors) are compared by identity.
+// Entries are kept in insertion order so printing is deterministic.
+type HashMap struct {
+	buckets map[uint64][]*list.Element
+	entries *list.List
+	// see NewHashMapLiteral
+	literalKeys   []*Value
+	literalValues []*Value
+}
+
+type hashEntry struct {
+	key   *Value
+	value *Value
+}
+
+func NewHashMap() *HashMap {
+	return &HashMap{buckets: make(map[uint64][]*list.Element), entries: list.New()}
+}
+
+// The map of a {} literal. Its key forms aren't evaluated yet, so equal forms
+// (e.g. two (next-id) calls) can still produce different keys. The map only
+// keeps the last of them, but if there are any the literal remembers all the
+// keys and values in order (see LiteralEntries)
+func NewHashMapLiteral(keys []*Value, values []*Value) *HashMap {
+	h := NewHashMap()
+	for i := range keys {
+		h.Set(keys[i], values[i])
+	}
+
+	if h.Len() != len(keys) {
+		h.literalKeys, h.literalValues = keys, values
+	}
+	return h
+}
+
+// Keys and values as written in the literal h was read from, equal keys
+// included. Same as Keys and Values for other maps and once h is modified
+func (h *HashMap) LiteralEntries() ([]*Value, []*Value) {
+	if h.literalKeys != nil {
+		return h.literalKeys, h.literalValues
+	}
+
+	return h.Keys(), h.Values()
+}
+
+func (h *HashMap) find(key *Value) (uint64, int) {
+	hashCode := Hash(key)
+	for i, e := range h.buckets[hashCode] {
+		if keyEqual(e.Value.(*hashEntry).key, key) {
+			return hashCode, i
+		}
+	}
+
+	return hashCode, -1
+}
+
+func (h *HashMap) Get(key *Value) (*Value, bool) {
+	hashCode, i := h.find(key)
+	if i < 0 {
+		return nil, false
+	}
+
+	return h.buckets[hashCode][i].Value.(*hashEntry).value, true
+}
+
+// Set replaces the value if the key is already present
+func (h *HashMap) Set(key *Value, value *Value) {
+	h.literalKeys, h.literalValues = nil, nil
+	hashCode, i := h.find(key)
+	if i >= 0 {
+		h.buckets[hashCode][i].Value.(*hashEntry).value = value
+		return
+	}
+
+	e := h.entries.PushBack(&hashEntry{key, value})
+	h.buckets[hashCode] = append(h.buckets[hashCode], e)
+}
+
+// Returns false if there was nothing to delete
+func (h *HashMap) Delete(key *Value) bool {
+	hashCode, i := h.find(key)
+	if i < 0 {
+		return false
+	}
+	h.literalKeys, h.literalValues = nil, nil
+
+	bucket := h.buckets[hashCode]
+	h.entries.Remove(bucket[i])
+	if len(bucket) == 1 {
+		delete(h.buckets, hashCode)
+	} else {
+		h.buckets[hashCode] = append(bucket[:i:i], bucket[i+1:]...)
+	}
+
+	return true
+}
+
+func (h *HashMap) Len() int {
+	return h.entries.Len()
+}
+
+// Calls f for every entry in insertion order
+func (h *HashMap) Each(f func(key *Value, value *Value)) {
+	for e := h.entries.Front(); e != nil; e = e.Next() {
+		entry := e.Value.(*hashEntry)
+		f(entry.key, entry.value)
+	}
+}
+
+func (h *HashMap) Keys() []*Value {
+	result := make([]*Value, 0, h.Len())
+	h.Each(func(key *Value, _ *Value) { result = append(result, key) })
+	return result
+}
+
+func (h *HashMap) Values() []*Value {
+	result := make([]*Value, 0, h.Len())
+	h.Each(func(_ *Value, value *Value) { result = append(result, value) })
+	return result
+}
+
+// Hash is consistent with keyEqual: equal keys have equal hashes. Mutable
+// collections are hashed by identity so changing them doesn't change it
+func Hash(v *Value) uint64 {
+	// symbols are the keys of the global bindings, so hashing them shouldn't
+	// allocate. Same result as writeHash
+	if v.IsSymbol() {
+		return fnvString(fnvByte(fnvOffset, byte(v.ValueType)), v.SymbolName())
+	}
+
+	h := fnv.New64a()
+	writeHash(h, v)
+	return h.Sum64()
+}
+
+// FNV-1a, as in hash/fnv
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+)
+
+func fnvByte(hash uint64, b byte) uint64 {
+	return (hash ^ uint64(b)) * fnvPrime
+}
+
+func fnvString(hash uint64, s string) uint64 {
+	for i := 0; i < len(s); i++ {
+		hash = fnvByte(hash, s[i])
+	}
+	return hash
+}
+
+func writeHash(h hash.Hash64, v *Value) {
+	h.Write([]byte{byte(v.ValueType)})
+
+	if v.IsSymbol() {
+		h.Write([]byte(v.SymbolName()))
+		return
+	}
+
+	if v.IsKeyword() {
+		h.Write([]byte(v.KeywordName()))
+		return
+	}
+
+	if v.IsInteger() {
+		binary.Write(h, binary.LittleEndian, int64(v.ToInt()))
+		return
+	}
+
+	if v.IsString() {
+		h.Write([]byte(v.ToStr()))
+		return
+	}
+
+	if v.IsBool() {
+		if v.ToBool() {
+			h.Write([]byte{1})
+		} else {
+			h.Write([]byte{0})
+		}
+		return
+	}
+
+	if v.IsCons() {
+		writeHash(h, v.Car())
+		writeHash(h, v.Cdr())
+		return
+	}
+
+	if v.IsGoObject() {
+		h.Write([]byte(goObjectHashKey(v)))
+		return
+	}
+
+	if v.IsNativeFn() || v.IsAtom() || v.IsMutex() || v.IsRef() || v.IsHashMap() || v.IsVector() {
+		binary.Write(h, binary.LittleEndian, uint64(reflect.ValueOf(v.Value).Pointer()))
+		return
+	}
+
+	if v.IsPVector() {
+		v.ToPVector().Each(func(_ int, item *Value) { writeHash(h, item) })
+		return
+	}
+
+	if v.IsPMap() {
+		var sum uint64
+		v.ToPMap().Each(func(key *Value, value *Value) {
+			sum += Hash(key)*31 + Hash(value)
+		})
+		binary.Write(h, binary.LittleEndian, sum)
+		return
+	}
+}
@@ -0,0 +1,34 @@
+package types
+
+import "sync"
+
+// Values that only exist once per name (symbols and keywords)
+type internTable struct {
+	sync.RWMutex
+	valueType ValueType
+	values    map[string]*Value
+}
+
+var (
+	symbols  = &internTable{valueType: symbolReference, values: make(map[string]*Value)}
+	keywords = &internTable{valueType: keywordReference, values: make(map[string]*Value)}
+)
+
+func (t *internTable) intern(name string) *Value {
+	t.RLock()
+	v, found := t.values[name]
+	t.RUnlock()
+	if found {
+		return v
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	if v, found := t.values[name]; found {
+		return v
+	}
+
+	v = &Value{t.valueType, name}
+	t.values[name] = v
+	return v
+}
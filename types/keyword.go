@@ -0,0 +1,14 @@
+package types
+
+// Name is without the leading colon. Keywords are interned
+func BuildKeyword(name string) *Value {
+	return keywords.intern(name)
+}
+
+func (kw *Value) KeywordName() string {
+	if !kw.IsKeyword() {
+		panic("Not a keyword")
+	}
+
+	return kw.Value.(string)
+}
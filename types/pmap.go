@@ -0,0 +1,263 @@
+package types
+
+import "math/bits"
+
+/*
+ * Persistent hash map: a hash array mapped trie (HAMT) keyed by keyEqual
+ * semantics. Each level consumes 5 bits of the key's Hash, so lookups take
+ * O(log32 n). Updates copy only the path to the changed entry.
+ *
+ * Never modified after creation so it's safe to share between goroutines.
+ */
+
+const (
+	hamtBits = 5
+	hamtMask = 1<<hamtBits - 1
+)
+
+type PMap struct {
+	count int
+	root  *hamtNode
+	// see NewPMapLiteral
+	literalKeys   []*Value
+	literalValues []*Value
+}
+
+// When collision is set, bitmap is unused and entries is a plain list of keys
+// with the same hash
+type hamtNode struct {
+	bitmap    uint32
+	entries   []hamtEntry
+	collision bool
+}
+
+// Either a key/value pair or a subtree
+type hamtEntry struct {
+	key   *Value
+	value *Value
+	node  *hamtNode
+}
+
+func EmptyPMap() *PMap {
+	return &PMap{count: 0, root: &hamtNode{}}
+}
+
+// The map of a #{} literal. Like with NewHashMapLiteral, equal key forms are
+// remembered (see LiteralEntries) so they can all be evaluated
+func NewPMapLiteral(keys []*Value, values []*Value) *PMap {
+	m := EmptyPMap()
+	for i := range keys {
+		m = m.Assoc(keys[i], values[i])
+	}
+
+	if m.Len() != len(keys) {
+		m = &PMap{count: m.count, root: m.root, literalKeys: keys, literalValues: values}
+	}
+	return m
+}
+
+// Keys and values as written in the literal m was read from, equal keys
+// included. Same as Keys and Values for other maps
+func (m *PMap) LiteralEntries() ([]*Value, []*Value) {
+	if m.literalKeys != nil {
+		return m.literalKeys, m.literalValues
+	}
+
+	return m.Keys(), m.Values()
+}
+
+func (m *PMap) Len() int {
+	return m.count
+}
+
+func (m *PMap) Get(key *Value) (*Value, bool) {
+	hash := Hash(key)
+	node := m.root
+	for shift := uint(0); ; shift += hamtBits {
+		if node.collision {
+			for _, e := range node.entries {
+				if keyEqual(e.key, key) {
+					return e.value, true
+				}
+			}
+			return nil, false
+		}
+
+		bit, i := node.position(hash, shift)
+		if node.bitmap&bit == 0 {
+			return nil, false
+		}
+
+		e := node.entries[i]
+		if e.node == nil {
+			if keyEqual(e.key, key) {
+				return e.value, true
+			}
+			return nil, false
+		}
+		node = e.node
+	}
+}
+
+// Returns a new map with key set to value
+func (m *PMap) Assoc(key *Value, value *Value) *PMap {
+	root, added := m.root.assoc(0, Hash(key), key, value)
+	if root == m.root && m.literalKeys == nil {
+		return m
+	}
+
+	if added {
+		return &PMap{count: m.count + 1, root: root}
+	}
+	return &PMap{count: m.count, root: root}
+}
+
+// Returns a new map without key
+func (m *PMap) Dissoc(key *Value) *PMap {
+	root, removed := m.root.dissoc(0, Hash(key), key)
+	if !removed {
+		return m
+	}
+
+	return &PMap{count: m.count - 1, root: root}
+}
+
+// Calls f for every entry. The order depends on key hashes
+func (m *PMap) Each(f func(key *Value, value *Value)) {
+	m.root.each(f)
+}
+
+func (m *PMap) Keys() []*Value {
+	result := make([]*Value, 0, m.count)
+	m.Each(func(key *Value, _ *Value) { result = append(result, key) })
+	return result
+}
+
+func (m *PMap) Values() []*Value {
+	result := make([]*Value, 0, m.count)
+	m.Each(func(_ *Value, value *Value) { result = append(result, value) })
+	return result
+}
+
+func (n *hamtNode) position(hash uint64, shift uint) (uint32, int) {
+	bit := uint32(1) << ((hash >> shift) & hamtMask)
+	return bit, bits.OnesCount32(n.bitmap & (bit - 1))
+}
+
+func (n *hamtNode) withEntry(i int, e hamtEntry) *hamtNode {
+	entries := make([]hamtEntry, len(n.entries))
+	copy(entries, n.entries)
+	entries[i] = e
+	return &hamtNode{n.bitmap, entries, n.collision}
+}
+
+func (n *hamtNode) withInserted(bit uint32, i int, e hamtEntry) *hamtNode {
+	entries := make([]hamtEntry, 0, len(n.entries)+1)
+	entries = append(entries, n.entries[:i]...)
+	entries = append(entries, e)
+	entries = append(entries, n.entries[i:]...)
+	return &hamtNode{n.bitmap | bit, entries, n.collision}
+}
+
+func (n *hamtNode) withRemoved(bit uint32, i int) *hamtNode {
+	entries := make([]hamtEntry, 0, len(n.entries)-1)
+	entries = append(entries, n.entries[:i]...)
+	entries = append(entries, n.entries[i+1:]...)
+	return &hamtNode{n.bitmap &^ bit, entries, n.collision}
+}
+
+func (n *hamtNode) assoc(shift uint, hash uint64, key *Value, value *Value) (*hamtNode, bool) {
+	if n.collision {
+		for i, e := range n.entries {
+			if keyEqual(e.key, key) {
+				return n.withEntry(i, hamtEntry{key: key, value: value}), false
+			}
+		}
+		return n.withInserted(0, len(n.entries), hamtEntry{key: key, value: value}), true
+	}
+
+	bit, i := n.position(hash, shift)
+	if n.bitmap&bit == 0 {
+		return n.withInserted(bit, i, hamtEntry{key: key, value: value}), true
+	}
+
+	e := n.entries[i]
+	if e.node != nil {
+		child, added := e.node.assoc(shift+hamtBits, hash, key, value)
+		if child == e.node {
+			return n, false
+		}
+		return n.withEntry(i, hamtEntry{node: child}), added
+	}
+
+	if keyEqual(e.key, key) {
+		if e.value == value {
+			return n, false
+		}
+		return n.withEntry(i, hamtEntry{key: key, value: value}), false
+	}
+
+	child := mergeHamtEntries(shift+hamtBits, e, Hash(e.key), hamtEntry{key: key, value: value}, hash)
+	return n.withEntry(i, hamtEntry{node: child}), true
+}
+
+func mergeHamtEntries(shift uint, a hamtEntry, hashA uint64, b hamtEntry, hashB uint64) *hamtNode {
+	if shift >= 64 {
+		return &hamtNode{entries: []hamtEntry{a, b}, collision: true}
+	}
+
+	bitA := uint32(1) << ((hashA >> shift) & hamtMask)
+	bitB := uint32(1) << ((hashB >> shift) & hamtMask)
+	if bitA == bitB {
+		child := mergeHamtEntries(shift+hamtBits, a, hashA, b, hashB)
+		return &hamtNode{bitmap: bitA, entries: []hamtEntry{{node: child}}}
+	}
+
+	if bitA < bitB {
+		return &hamtNode{bitmap: bitA | bitB, entries: []hamtEntry{a, b}}
+	}
+	return &hamtNode{bitmap: bitA | bitB, entries: []hamtEntry{b, a}}
+}
+
+func (n *hamtNode) dissoc(shift uint, hash uint64, key *Value) (*hamtNode, bool) {
+	if n.collision {
+		for i, e := range n.entries {
+			if keyEqual(e.key, key) {
+				return n.withRemoved(0, i), true
+			}
+		}
+		return n, false
+	}
+
+	bit, i := n.position(hash, shift)
+	if n.bitmap&bit == 0 {
+		return n, false
+	}
+
+	e := n.entries[i]
+	if e.node != nil {
+		child, removed := e.node.dissoc(shift+hamtBits, hash, key)
+		if !removed {
+			return n, false
+		}
+		if len(child.entries) == 0 {
+			return n.withRemoved(bit, i), true
+		}
+		return n.withEntry(i, hamtEntry{node: child}), true
+	}
+
+	if keyEqual(e.key, key) {
+		return n.withRemoved(bit, i), true
+	}
+	return n, false
+}
+
+func (n *hamtNode) each(f func(key *Value, value *Value)) {
+	for _, e := range n.entries {
+		if e.node != nil {
+			e.node.each(f)
+		} else {
+			f(e.key, e.value)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package types
+
+/*
+ * Persistent vector: a 32-way trie with a tail buffer (same layout as
+ * Clojure's PersistentVector). Updates copy only the path to the changed
+ * leaf, everything else is shared between versions.
+ *
+ * Never modified after creation so it's safe to share between goroutines.
+ */
+
+const (
+	pvBits  = 5
+	pvWidth = 1 << pvBits
+	pvMask  = pvWidth - 1
+)
+
+type PVector struct {
+	count int
+	shift uint
+	root  *pvNode
+	tail  []*Value
+}
+
+// Internal nodes use children, leaves use values
+type pvNode struct {
+	children []*pvNode
+	values   []*Value
+}
+
+func EmptyPVector() *PVector {
+	return &PVector{0, pvBits, &pvNode{}, nil}
+}
+
+func (v *PVector) Len() int {
+	return v.count
+}
+
+func (v *PVector) tailOffset() int {
+	if v.count < pvWidth {
+		return 0
+	}
+
+	return ((v.count - 1) >> pvBits) << pvBits
+}
+
+// Panics if i is out of range
+func (v *PVector) Get(i int) *Value {
+	if i < 0 || i >= v.count {
+		panic("PVector index out of range")
+	}
+
+	if i >= v.tailOffset() {
+		return v.tail[i&pvMask]
+	}
+
+	node := v.root
+	for level := v.shift; level > 0; level -= pvBits {
+		node = node.children[(i>>level)&pvMask]
+	}
+
+	return node.values[i&pvMask]
+}
+
+// Returns a new vector with value appended
+func (v *PVector) Conj(value *Value) *PVector {
+	if v.count-v.tailOffset() < pvWidth {
+		tail := make([]*Value, len(v.tail), len(v.tail)+1)
+		copy(tail, v.tail)
+		return &PVector{v.count + 1, v.shift, v.root, append(tail, value)}
+	}
+
+	tailNode := &pvNode{values: v.tail}
+	shift := v.shift
+	var root *pvNode
+	if (v.count >> pvBits) > (1 << v.shift) {
+		root = &pvNode{children: []*pvNode{v.root, newPVPath(v.shift, tailNode)}}
+		shift += pvBits
+	} else {
+		root = v.pushTail(v.shift, v.root, tailNode)
+	}
+
+	return &PVector{v.count + 1, shift, root, []*Value{value}}
+}
+
+func (v *PVector) pushTail(level uint, parent *pvNode, tailNode *pvNode) *pvNode {
+	subIndex := ((v.count - 1) >> level) & pvMask
+	children := make([]*pvNode, len(parent.children), len(parent.children)+1)
+	copy(children, parent.children)
+
+	var child *pvNode
+	if level == pvBits {
+		child = tailNode
+	} else if subIndex < len(parent.children) {
+		child = v.pushTail(level-pvBits, parent.children[subIndex], tailNode)
+	} else {
+		child = newPVPath(level-pvBits, tailNode)
+	}
+
+	if subIndex < len(children) {
+		children[subIndex] = child
+	} else {
+		children = append(children, child)
+	}
+
+	return &pvNode{children: children}
+}
+
+func newPVPath(level uint, node *pvNode) *pvNode {
+	if level == 0 {
+		return node
+	}
+
+	return &pvNode{children: []*pvNode{newPVPath(level-pvBits, node)}}
+}
+
+// Returns a new vector with i-th element replaced. i == Len() appends.
+// Panics if i is out of range
+func (v *PVector) Assoc(i int, value *Value) *PVector {
+	if i == v.count {
+		return v.Conj(value)
+	}
+
+	if i < 0 || i > v.count {
+		panic("PVector index out of range")
+	}
+
+	if i >= v.tailOffset() {
+		tail := make([]*Value, len(v.tail))
+		copy(tail, v.tail)
+		tail[i&pvMask] = value
+		return &PVector{v.count, v.shift, v.root, tail}
+	}
+
+	return &PVector{v.count, v.shift, assocPV(v.shift, v.root, i, value), v.tail}
+}
+
+func assocPV(level uint, node *pvNode, i int, value *Value) *pvNode {
+	if level == 0 {
+		values := make([]*Value, len(node.values))
+		copy(values, node.values)
+		values[i&pvMask] = value
+		return &pvNode{values: values}
+	}
+
+	children := make([]*pvNode, len(node.children))
+	copy(children, node.children)
+	subIndex := (i >> level) & pvMask
+	children[subIndex] = assocPV(level-pvBits, children[subIndex], i, value)
+	return &pvNode{children: children}
+}
+
+// Calls f for every element in order
+func (v *PVector) Each(f func(i int, value *Value)) {
+	for i := 0; i < v.count; i++ {
+		f(i, v.Get(i))
+	}
+}
+
+func (v *PVector) ToList() *Value {
+	result := BuildEmptyList()
+	for i := v.count - 1; i >= 0; i-- {
+		result = BuildCons(v.Get(i), result)
+	}
+
+	return result
+}
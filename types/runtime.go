@@ -0,0 +1,78 @@
+package types
+
+import (
+	"context"
+	"io"
+)
+
+// Runtime is the state of a single evaluation: where output goes, how files
+// are loaded and how much work it's allowed to do. Natives can get it from
+// their bindings with Bindings.Runtime.
+//
+// Counters aren't synchronised, so a runtime must not be shared by concurrent
+// evaluations.
+type Runtime struct {
+	Context context.Context
+	Stdout  io.Writer
+	Stderr  io.Writer
+	// Used by load instead of os.ReadFile if set
+	ReadFile func(name string) ([]byte, error)
+
+	// Zero means unlimited
+	MaxSteps int
+	MaxDepth int
+
+	steps int
+	depth int
+}
+
+type LimitExceededError struct {
+	Limit string
+}
+
+func (e *LimitExceededError) Error() string { return "evaluation limit exceeded: " + e.Limit }
+
+// How often (in steps) the context is checked for cancellation
+const contextCheckInterval = 256
+
+// Called by the evaluator before every step. Has to be paired with Leave
+func (rt *Runtime) Enter() error {
+	rt.steps++
+	rt.depth++
+
+	if rt.MaxSteps > 0 && rt.steps > rt.MaxSteps {
+		return &LimitExceededError{"max steps"}
+	}
+
+	if rt.MaxDepth > 0 && rt.depth > rt.MaxDepth {
+		return &LimitExceededError{"max depth"}
+	}
+
+	if rt.Context != nil && (rt.steps == 1 || rt.steps%contextCheckInterval == 0) {
+		return rt.Context.Err()
+	}
+
+	return nil
+}
+
+func (rt *Runtime) Leave() {
+	rt.depth--
+}
+
+// Number of steps taken so far
+func (rt *Runtime) Steps() int {
+	return rt.steps
+}
+
+// A runtime with the same settings and fresh counters, for evaluations running
+// concurrently with this one (limits apply to each of them separately)
+func (rt *Runtime) Fork() *Runtime {
+	return &Runtime{
+		Context:  rt.Context,
+		Stdout:   rt.Stdout,
+		Stderr:   rt.Stderr,
+		ReadFile: rt.ReadFile,
+		MaxSteps: rt.MaxSteps,
+		MaxDepth: rt.MaxDepth,
+	}
+}
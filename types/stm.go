@@ -0,0 +1,163 @@
+package types
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+ * Software transactional memory. Refs are changed only inside transactions,
+ * which see a consistent view of the refs they use and commit all their
+ * changes at once. A transaction that read a ref changed by someone else
+ * before it committed is retried from the start, so transaction bodies should
+ * have no side effects other than changing refs.
+ */
+
+type Ref struct {
+	id uint64
+
+	mu      sync.RWMutex
+	value   *Value
+	version uint64
+}
+
+var lastRefID atomic.Uint64
+
+func NewRef(v *Value) *Ref {
+	return &Ref{id: lastRefID.Add(1), value: v}
+}
+
+// Current committed value
+func (r *Ref) Load() *Value {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.value
+}
+
+func (r *Ref) load() (*Value, uint64) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.value, r.version
+}
+
+// Returned by transaction operations when the transaction has to be retried
+var ErrTransactionConflict = errors.New("transaction conflict")
+
+// Give up instead of retrying forever
+const maxTransactionRetries = 10000
+
+type Transaction struct {
+	// version of every ref when it was first read
+	reads  map[*Ref]uint64
+	writes map[*Ref]*Value
+}
+
+func NewTransaction() *Transaction {
+	return &Transaction{make(map[*Ref]uint64), make(map[*Ref]*Value)}
+}
+
+// Value of r as seen by the transaction. The first time r is read, the refs
+// read before it are checked to be unchanged, so all the values the
+// transaction has seen were current at the same moment
+func (tx *Transaction) Get(r *Ref) (*Value, error) {
+	if value, found := tx.writes[r]; found {
+		return value, nil
+	}
+
+	value, version := r.load()
+	if seen, found := tx.reads[r]; found {
+		if seen != version {
+			return nil, ErrTransactionConflict
+		}
+		return value, nil
+	}
+
+	// commits lock all their refs until done, so r's version can't be newer
+	// than these if they're unchanged
+	for other, seen := range tx.reads {
+		if _, current := other.load(); current != seen {
+			return nil, ErrTransactionConflict
+		}
+	}
+	tx.reads[r] = version
+
+	return value, nil
+}
+
+// Changes r when (if) the transaction commits
+func (tx *Transaction) Set(r *Ref, v *Value) {
+	tx.writes[r] = v
+}
+
+// Applies the changes unless any ref read by the transaction has been changed
+// since. Returns false in that case
+func (tx *Transaction) Commit() bool {
+	refs := make([]*Ref, 0, len(tx.reads)+len(tx.writes))
+	for r := range tx.reads {
+		refs = append(refs, r)
+	}
+	for r := range tx.writes {
+		if _, found := tx.reads[r]; !found {
+			refs = append(refs, r)
+		}
+	}
+
+	// always locking in the same order avoids deadlocks
+	sort.Slice(refs, func(i, j int) bool { return refs[i].id < refs[j].id })
+	for _, r := range refs {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+	}
+
+	for r, version := range tx.reads {
+		if r.version != version {
+			return false
+		}
+	}
+
+	for r, value := range tx.writes {
+		r.value = value
+		r.version++
+	}
+
+	return true
+}
+
+// Runs f in a transaction, retrying on conflicts. Errors other than
+// ErrTransactionConflict abort the transaction
+func Dosync(f func(tx *Transaction) (*Value, error)) (*Value, error) {
+	for i := 0; i < maxTransactionRetries; i++ {
+		tx := NewTransaction()
+		result, err := f(tx)
+		if errors.Is(err, ErrTransactionConflict) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if tx.Commit() {
+			return result, nil
+		}
+	}
+
+	return nil, errors.New("dosync: too many retries")
+}
+
+func BuildRef(r *Ref) *Value {
+	return &Value{refReference, r}
+}
+
+func (v *Value) IsRef() bool { return v.ValueType == refReference }
+
+func (v *Value) ToRef() *Ref {
+	if !v.IsRef() {
+		panic("Not a ref")
+	}
+
+	return v.Value.(*Ref)
+}
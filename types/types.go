@@ -1,16 +1,61 @@
 package types
 
+import "slices"
+
+// Small integer kind so type checks don't compare strings
+type ValueType uint8
+
 const (
-	symbolReference    = "sym"
-	integerReference   = "int"
-	consReference      = "cons"
-	emptyListReference = "()"
-	nativeFnReference  = "<native fn>"
-	stringReference    = "string"
+	symbolReference ValueType = iota
+	integerReference
+	consReference
+	emptyListReference
+	nativeFnReference
+	stringReference
+	hashMapReference
+	vectorReference
+	pvectorReference
+	pmapReference
+	boolReference
+	keywordReference
+	goObjectReference
+	atomReference
+	mutexReference
+	refReference
+)
+
+var valueTypeNames = [...]string{
+	symbolReference:    "symbol",
+	integerReference:   "integer",
+	consReference:      "cons",
+	emptyListReference: "()",
+	nativeFnReference:  "native fn",
+	stringReference:    "string",
+	hashMapReference:   "hash map",
+	vectorReference:    "vector",
+	pvectorReference:   "persistent vector",
+	pmapReference:      "persistent map",
+	boolReference:      "bool",
+	keywordReference:   "keyword",
+	goObjectReference:  "go object",
+	atomReference:      "atom",
+	mutexReference:     "mutex",
+	refReference:       "ref",
+}
+
+func (t ValueType) String() string {
+	return valueTypeNames[t]
+}
+
+// The only two boolean values. BuildBool always returns one of these so
+// predicates have a canonical truth value
+var (
+	trueValue  = &Value{boolReference, true}
+	falseValue = &Value{boolReference, false}
 )
 
 type Value struct {
-	ValueType string
+	ValueType ValueType
 	Value     any
 }
 
@@ -19,8 +64,16 @@ type Cons struct {
 	Cdr *Value
 }
 
+type NativeFn struct {
+	Fn func(*Bindings, *Value) (*Value, error)
+	// When set, arguments are evaluated before calling Fn, so Fn receives
+	// values instead of forms (like a lambda with a parameter list)
+	EvalArgs bool
+}
+
+// Symbols are interned so they can be compared by pointer
 func BuildSymbol(name string) *Value {
-	return &Value{symbolReference, name}
+	return symbols.intern(name)
 }
 
 func BuildInteger(n int) *Value {
@@ -35,20 +88,72 @@ func BuildEmptyList() *Value {
 	return &Value{emptyListReference, nil}
 }
 
+// f receives its arguments unevaluated
 func BuildNativeFn(f func(*Bindings, *Value) (*Value, error)) *Value {
-	return &Value{nativeFnReference, f}
+	return &Value{nativeFnReference, &NativeFn{Fn: f}}
+}
+
+// f receives a list of already evaluated arguments
+func BuildApplicativeFn(f func(*Bindings, *Value) (*Value, error)) *Value {
+	return &Value{nativeFnReference, &NativeFn{Fn: f, EvalArgs: true}}
 }
 
 func BuildString(s string) *Value {
 	return &Value{stringReference, s}
 }
 
+func BuildHashMap(h *HashMap) *Value {
+	return &Value{hashMapReference, h}
+}
+
+func BuildVector(items []*Value) *Value {
+	return &Value{vectorReference, &Vector{items}}
+}
+
+func BuildPVector(v *PVector) *Value {
+	return &Value{pvectorReference, v}
+}
+
+func BuildPMap(m *PMap) *Value {
+	return &Value{pmapReference, m}
+}
+
+func BuildBool(b bool) *Value {
+	if b {
+		return trueValue
+	}
+
+	return falseValue
+}
+
 func (v *Value) IsSymbol() bool { return v.ValueType == symbolReference }
 func (v *Value) IsInteger() bool { return v.ValueType == integerReference }
 func (v *Value) IsCons() bool { return v.ValueType == consReference }
 func (v *Value) IsEmptyList() bool { return v.ValueType == emptyListReference }
 func (v *Value) IsNativeFn() bool { return v.ValueType == nativeFnReference }
 func (v *Value) IsString() bool { return v.ValueType == stringReference }
+func (v *Value) IsHashMap() bool { return v.ValueType == hashMapReference }
+func (v *Value) IsVector() bool { return v.ValueType == vectorReference }
+func (v *Value) IsPVector() bool { return v.ValueType == pvectorReference }
+func (v *Value) IsPMap() bool { return v.ValueType == pmapReference }
+func (v *Value) IsBool() bool { return v.ValueType == boolReference }
+func (v *Value) IsKeyword() bool { return v.ValueType == keywordReference }
+
+// Values that evaluate to themselves
+func (v *Value) IsSelfEvaluating() bool {
+	switch v.ValueType {
+	case integerReference, emptyListReference, stringReference, boolReference, keywordReference,
+		goObjectReference, atomReference, mutexReference, refReference:
+		return true
+	}
+
+	return false
+}
+
+// Everything is true except the empty list and #f
+func (v *Value) IsTruthy() bool {
+	return !v.IsEmptyList() && !(v.IsBool() && !v.ToBool())
+}
 
 func (v *Value) IsList() bool {
 	iter := v
@@ -81,8 +186,10 @@ func (sym *Value) SymbolName() string {
 	return sym.Value.(string)
 }
 
+var lambdaSymbol = BuildSymbol("lambda")
+
 func (v *Value) IsLambdaSymbol() bool {
-	return v.IsSymbol() && v.SymbolName() == "lambda"
+	return v == lambdaSymbol
 }
 
 func (n *Value) ToInt() int {
@@ -93,6 +200,14 @@ func (n *Value) ToInt() int {
 	return n.Value.(int)
 }
 
+func (b *Value) ToBool() bool {
+	if !b.IsBool() {
+		panic("Not a bool")
+	}
+
+	return b.Value.(bool)
+}
+
 func (s *Value) ToStr() string {
 	if !s.IsString() {
 		panic("Not a string")
@@ -101,6 +216,38 @@ func (s *Value) ToStr() string {
 	return s.Value.(string)
 }
 
+func (h *Value) ToHashMap() *HashMap {
+	if !h.IsHashMap() {
+		panic("Not a hash map")
+	}
+
+	return h.Value.(*HashMap)
+}
+
+func (v *Value) ToVector() *Vector {
+	if !v.IsVector() {
+		panic("Not a vector")
+	}
+
+	return v.Value.(*Vector)
+}
+
+func (v *Value) ToPVector() *PVector {
+	if !v.IsPVector() {
+		panic("Not a persistent vector")
+	}
+
+	return v.Value.(*PVector)
+}
+
+func (m *Value) ToPMap() *PMap {
+	if !m.IsPMap() {
+		panic("Not a persistent map")
+	}
+
+	return m.Value.(*PMap)
+}
+
 func (c *Value) Car() *Value {
 	if c.IsEmptyList() {
 		return c
@@ -126,11 +273,68 @@ func (c *Value) Cdr() *Value {
 }
 
 func (f *Value) NativeFn() (func(*Bindings, *Value) (*Value, error)) {
-	return f.Value.(func(*Bindings, *Value) (*Value, error))
+	return f.ToNativeFn().Fn
+}
+
+func (f *Value) ToNativeFn() *NativeFn {
+	if !f.IsNativeFn() {
+		panic("Not a native fn")
+	}
+
+	return f.Value.(*NativeFn)
 }
 
 
 func Equal(a *Value, b *Value) bool {
+	return equal(a, b, false)
+}
+
+// Equal for hash map keys: mutable collections (hash maps and vectors) are
+// compared by identity, at any depth, so changing them can't corrupt a map
+// they're keys of. Consistent with Hash
+func keyEqual(a *Value, b *Value) bool {
+	return equal(a, b, true)
+}
+
+// Whether the entries of two maps can be paired up so that keys and values
+// are equal. Maps look keys up with keyEqual, so e.g. two different [1] keys
+// aren't found in each other's map: the entries Get can't pair up are then
+// matched one by one
+func entriesEqual(xKeys, xValues []*Value, xGet func(*Value) (*Value, bool), yKeys, yValues []*Value, yGet func(*Value) (*Value, bool), mutableByIdentity bool) bool {
+	if len(xKeys) != len(yKeys) {
+		return false
+	}
+
+	unpaired := func(keys, values []*Value, get func(*Value) (*Value, bool)) []int {
+		var result []int
+		for i, key := range keys {
+			other, found := get(key)
+			if !found || !equal(values[i], other, mutableByIdentity) {
+				result = append(result, i)
+			}
+		}
+		return result
+	}
+
+	xRest, yRest := unpaired(xKeys, xValues, yGet), unpaired(yKeys, yValues, xGet)
+	if len(xRest) != len(yRest) {
+		return false
+	}
+
+	// equal is an equivalence, so pairing greedily can't miss a pairing
+	for _, i := range xRest {
+		j := slices.IndexFunc(yRest, func(j int) bool {
+			return equal(xKeys[i], yKeys[j], mutableByIdentity) && equal(xValues[i], yValues[j], mutableByIdentity)
+		})
+		if j < 0 {
+			return false
+		}
+		yRest = slices.Delete(yRest, j, j+1)
+	}
+	return true
+}
+
+func equal(a *Value, b *Value, mutableByIdentity bool) bool {
 	if a.ValueType != b.ValueType {
 		return false
 	}
@@ -140,8 +344,9 @@ func Equal(a *Value, b *Value) bool {
 		return true
 	}
 
+	// interned
 	if a.IsSymbol() {
-		return a.SymbolName() == b.SymbolName()
+		return a == b
 	}
 
 	if a.IsInteger() {
@@ -149,7 +354,7 @@ func Equal(a *Value, b *Value) bool {
 	}
 
 	if a.IsCons() {
-		return Equal(a.Car(), b.Car()) && Equal(a.Cdr(), b.Cdr())
+		return equal(a.Car(), b.Car(), mutableByIdentity) && equal(a.Cdr(), b.Cdr(), mutableByIdentity)
 	}
 
 	if a.IsNativeFn() {
@@ -160,5 +365,64 @@ func Equal(a *Value, b *Value) bool {
 		return a.Value == b.Value
 	}
 
+	if a.IsBool() {
+		return a.ToBool() == b.ToBool()
+	}
+
+	// interned
+	if a.IsKeyword() {
+		return a == b
+	}
+
+	if (a.IsHashMap() || a.IsVector()) && mutableByIdentity {
+		return a.Value == b.Value
+	}
+
+	if a.IsHashMap() {
+		x, y := a.ToHashMap(), b.ToHashMap()
+		return entriesEqual(x.Keys(), x.Values(), x.Get, y.Keys(), y.Values(), y.Get, mutableByIdentity)
+	}
+
+	if a.IsVector() {
+		x, y := a.ToVector(), b.ToVector()
+		if x.Len() != y.Len() {
+			return false
+		}
+
+		for i := range x.Items {
+			if !equal(x.Items[i], y.Items[i], mutableByIdentity) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if a.IsPVector() {
+		x, y := a.ToPVector(), b.ToPVector()
+		if x.Len() != y.Len() {
+			return false
+		}
+
+		for i := 0; i < x.Len(); i++ {
+			if !equal(x.Get(i), y.Get(i), mutableByIdentity) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if a.IsGoObject() {
+		return goObjectsEqual(a, b)
+	}
+
+	if a.IsAtom() || a.IsMutex() || a.IsRef() {
+		return a.Value == b.Value
+	}
+
+	if a.IsPMap() {
+		x, y := a.ToPMap(), b.ToPMap()
+		return entriesEqual(x.Keys(), x.Values(), x.Get, y.Keys(), y.Values(), y.Get, mutableByIdentity)
+	}
+
 	panic("unexpected value type")
 }
@@ -0,0 +1,31 @@
+package types
+
+// Vector is a mutable sequence backed by a Go slice
+type Vector struct {
+	Items []*Value
+}
+
+func (v *Vector) Len() int {
+	return len(v.Items)
+}
+
+func (v *Vector) Get(i int) *Value {
+	return v.Items[i]
+}
+
+func (v *Vector) Set(i int, value *Value) {
+	v.Items[i] = value
+}
+
+func (v *Vector) Push(value *Value) {
+	v.Items = append(v.Items, value)
+}
+
+func (v *Vector) ToList() *Value {
+	result := BuildEmptyList()
+	for i := len(v.Items) - 1; i >= 0; i-- {
+		result = BuildCons(v.Items[i], result)
+	}
+
+	return result
+}
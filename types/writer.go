@@ -15,26 +15,37 @@ func (v *Value) PrintStr() string {
 		return strconv.Itoa(v.ToInt())
 	}
 
+	if v.IsKeyword() {
+		return ":" + v.KeywordName()
+	}
+
 	if v.IsEmptyList() {
 		return "()"
 	}
 
-	if v.IsList() {
+	if v.IsBool() {
+		if v.ToBool() {
+			return "#t"
+		}
+		return "#f"
+	}
+
+	// (a b c) or (a b . c)
+	if v.IsCons() {
 		res := "("
 		res += v.Car().PrintStr()
 		iter := v.Cdr()
-		for !iter.IsEmptyList() {
+		for iter.IsCons() {
 			res += " " + iter.Car().PrintStr()
 			iter = iter.Cdr()
 		}
+		if !iter.IsEmptyList() {
+			res += " . " + iter.PrintStr()
+		}
 		res += ")"
 		return res
 	}
 
-	if v.IsCons() {
-		return fmt.Sprintf("(%v . %s)", v.Car().PrintStr(), v.Cdr().PrintStr())
-	}
-
 	if v.IsNativeFn() {
 		return "<native fn>"
 	}
@@ -44,5 +55,73 @@ func (v *Value) PrintStr() string {
 		return fmt.Sprintf(`"%s"`, escaped)
 	}
 
+	if v.IsHashMap() {
+		res := "{"
+		first := true
+		v.ToHashMap().Each(func(key *Value, value *Value) {
+			if !first {
+				res += " "
+			}
+			first = false
+			res += key.PrintStr() + " " + value.PrintStr()
+		})
+		res += "}"
+		return res
+	}
+
+	if v.IsVector() {
+		res := "["
+		for i, item := range v.ToVector().Items {
+			if i > 0 {
+				res += " "
+			}
+			res += item.PrintStr()
+		}
+		res += "]"
+		return res
+	}
+
+	if v.IsPVector() {
+		res := "#["
+		v.ToPVector().Each(func(i int, item *Value) {
+			if i > 0 {
+				res += " "
+			}
+			res += item.PrintStr()
+		})
+		res += "]"
+		return res
+	}
+
+	if v.IsPMap() {
+		res := "#{"
+		first := true
+		v.ToPMap().Each(func(key *Value, value *Value) {
+			if !first {
+				res += " "
+			}
+			first = false
+			res += key.PrintStr() + " " + value.PrintStr()
+		})
+		res += "}"
+		return res
+	}
+
+	if v.IsGoObject() {
+		return goObjectPrintStr(v)
+	}
+
+	if v.IsAtom() {
+		return "#<atom " + v.ToAtom().Load().PrintStr() + ">"
+	}
+
+	if v.IsMutex() {
+		return "#<mutex>"
+	}
+
+	if v.IsRef() {
+		return "#<ref " + v.ToRef().Load().PrintStr() + ">"
+	}
+
 	panic("Can't convert to string")
 }
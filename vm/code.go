@@ -0,0 +1,75 @@
+package vm
+
+import (
+	. "nondv.io/glisp/types"
+)
+
+type Opcode byte
+
+const (
+	// Push Constants[A]
+	OpConst Opcode = iota
+	// Push the B-th value of the local frame A levels up
+	OpLocal
+	// Push the value of symbol Constants[A] (dynamic lookup)
+	OpGlobal
+	// Jump to A
+	OpJump
+	// Pop a value and jump to A if it's false
+	OpJumpIfFalse
+	// Discard the top of the stack
+	OpPop
+	// Peek at the function on top of the stack. If it doesn't evaluate its
+	// arguments, pop it, call it through the interpreter with the argument
+	// forms Constants[A], push the result and jump to B
+	OpPrepareCall
+	// Pop A arguments and a function, push the result of the call
+	OpCall
+	// Push the result of interpreter.Eval(Constants[A])
+	OpCallOut
+	// Pop a value and bind it to symbol Constants[A] in a new local frame
+	OpBind
+	// Drop A innermost local frames created by OpBind
+	OpUnbind
+	// Jump to B if symbol Constants[A] doesn't refer to the builtin special
+	// form with the same name
+	OpCheckSpecial
+	// Return the top of the stack
+	OpReturn
+)
+
+var opcodeNames = [...]string{
+	OpConst:        "CONST",
+	OpLocal:        "LOCAL",
+	OpGlobal:       "GLOBAL",
+	OpJump:         "JUMP",
+	OpJumpIfFalse:  "JUMP_IF_FALSE",
+	OpPop:          "POP",
+	OpPrepareCall:  "PREPARE_CALL",
+	OpCall:         "CALL",
+	OpCallOut:      "CALL_OUT",
+	OpBind:         "BIND",
+	OpUnbind:       "UNBIND",
+	OpCheckSpecial: "CHECK_SPECIAL",
+	OpReturn:       "RETURN",
+}
+
+func (op Opcode) String() string {
+	return opcodeNames[op]
+}
+
+type Instruction struct {
+	Op Opcode
+	A  int
+	B  int
+}
+
+// Code is a compiled lambda (or a top-level form, which is compiled as a
+// lambda without parameters)
+type Code struct {
+	Params       []*Value
+	Instructions []Instruction
+	Constants    []*Value
+	// Original form, for debugging
+	Source *Value
+}
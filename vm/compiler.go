@@ -0,0 +1,232 @@
+package vm
+
+import (
+	"errors"
+
+	"nondv.io/glisp/interpreter"
+	. "nondv.io/glisp/types"
+)
+
+/*
+ * Bytecode compiler for lambdas with parameter lists, i.e. the ones that
+ * evaluate their arguments.
+ *
+ * Any symbol can be rebound at runtime and lambdas with a symbol parameter
+ * (fexprs) receive their arguments unevaluated, so:
+ *
+ * - if and let are guarded by OpCheckSpecial and call out to the interpreter
+ *   if they've been rebound;
+ * - function calls check the function at runtime (OpPrepareCall) and call out
+ *   to the interpreter with the argument forms if it doesn't evaluate its
+ *   arguments;
+ * - forms the compiler doesn't understand are evaluated by the interpreter.
+ */
+
+type compiler struct {
+	code  *Code
+	scope *interpreter.Scope
+}
+
+// lambda -> *Code
+var lambdaCache interpreter.FormCache[*Code]
+
+// Compiles a lambda with a list of symbols as parameters
+func CompileLambda(lambda *Value) (*Code, error) {
+	if !lambda.IsList() || !lambda.Car().IsLambdaSymbol() {
+		return nil, errors.New("Not a lambda")
+	}
+
+	syms, simple := interpreter.SimpleParams(lambda.Cdr().Car())
+	if !simple {
+		return nil, errors.New("only lambdas with plain parameter lists can be compiled")
+	}
+
+	c := &compiler{&Code{Params: syms, Source: lambda}, &interpreter.Scope{Symbols: syms}}
+	c.compileBody(lambda.Cdr().Cdr())
+	c.emit(OpReturn, 0, 0)
+	return c.code, nil
+}
+
+// Compiles a top-level form. Running it is the same as interpreter.Eval
+func Compile(form *Value) *Code {
+	c := &compiler{&Code{Source: form}, nil}
+	c.compile(form)
+	c.emit(OpReturn, 0, 0)
+	return c.code
+}
+
+func cachedLambda(fn *Value) *Code {
+	if !fn.IsCons() || !fn.IsList() || !fn.Car().IsLambdaSymbol() {
+		return nil
+	}
+
+	if cached, found := lambdaCache.Load(fn); found {
+		return cached
+	}
+
+	// lambdas that can't be compiled aren't cached, parsing lambda lists is
+	// cached already
+	code, err := CompileLambda(fn)
+	if err != nil {
+		return nil
+	}
+
+	// the cache must not keep the lambda alive
+	code.Source = nil
+	return lambdaCache.Store(fn, code)
+}
+
+func (c *compiler) emit(op Opcode, a int, b int) int {
+	c.code.Instructions = append(c.code.Instructions, Instruction{op, a, b})
+	return len(c.code.Instructions) - 1
+}
+
+// Sets A (or B) of a previously emitted jump to the current position
+func (c *compiler) patchA(i int) {
+	c.code.Instructions[i].A = len(c.code.Instructions)
+}
+
+func (c *compiler) patchB(i int) {
+	c.code.Instructions[i].B = len(c.code.Instructions)
+}
+
+func (c *compiler) constant(v *Value) int {
+	for i, existing := range c.code.Constants {
+		if existing == v {
+			return i
+		}
+	}
+
+	c.code.Constants = append(c.code.Constants, v)
+	return len(c.code.Constants) - 1
+}
+
+func (c *compiler) compile(form *Value) {
+	if form.IsSelfEvaluating() {
+		c.emit(OpConst, c.constant(form), 0)
+		return
+	}
+
+	if form.IsSymbol() {
+		if depth, i, found := c.scope.Resolve(form); found {
+			c.emit(OpLocal, depth, i)
+		} else {
+			c.emit(OpGlobal, c.constant(form), 0)
+		}
+		return
+	}
+
+	if form.IsList() {
+		if form.Car().IsLambdaSymbol() {
+			c.emit(OpConst, c.constant(form), 0)
+			return
+		}
+
+		c.compileCall(form)
+		return
+	}
+
+	// including improper calls like (f 1 . 2), Eval reports those
+	c.emit(OpCallOut, c.constant(form), 0)
+}
+
+func (c *compiler) compileCall(form *Value) {
+	fn := form.Car()
+	args := form.Cdr()
+
+	if fn.IsSymbol() {
+		if _, _, local := c.scope.Resolve(fn); !local {
+			switch interpreter.SpecialForm(fn.SymbolName()) {
+			case nil:
+			case interpreter.SpecialForm("if"):
+				if args.ListLength() == 3 {
+					c.compileIf(form)
+					return
+				}
+			case interpreter.SpecialForm("let"):
+				if interpreter.SimpleLet(args) {
+					c.compileLet(form)
+					return
+				}
+			}
+		}
+	}
+
+	c.compile(fn)
+	prepare := c.emit(OpPrepareCall, c.constant(args), 0)
+	argc := 0
+	for iter := args; !iter.IsEmptyList(); iter = iter.Cdr() {
+		c.compile(iter.Car())
+		argc++
+	}
+	c.emit(OpCall, argc, 0)
+	c.patchB(prepare)
+}
+
+// Emits the guard; returns the index to patch with the fallback address
+func (c *compiler) checkSpecial(form *Value) int {
+	return c.emit(OpCheckSpecial, c.constant(form.Car()), 0)
+}
+
+// Emits the fallback (call out to the interpreter) and patches the guard
+func (c *compiler) fallback(form *Value, guard int, jumpsToEnd ...int) {
+	c.patchB(guard)
+	c.emit(OpCallOut, c.constant(form), 0)
+	for _, jump := range jumpsToEnd {
+		c.patchA(jump)
+	}
+}
+
+// (if CONDITION THEN ELSE)
+func (c *compiler) compileIf(form *Value) {
+	args := form.Cdr()
+	guard := c.checkSpecial(form)
+
+	c.compile(args.Car())
+	jumpToElse := c.emit(OpJumpIfFalse, 0, 0)
+	c.compile(args.Cdr().Car())
+	jumpFromThen := c.emit(OpJump, 0, 0)
+	c.patchA(jumpToElse)
+	c.compile(args.Cdr().Cdr().Car())
+	jumpFromElse := c.emit(OpJump, 0, 0)
+
+	c.fallback(form, guard, jumpFromThen, jumpFromElse)
+}
+
+// (let ((SYMBOL SEXP) ...) BODY...)
+func (c *compiler) compileLet(form *Value) {
+	args := form.Cdr()
+	guard := c.checkSpecial(form)
+
+	outer := c.scope
+	count := 0
+	for iter := args.Car(); !iter.IsEmptyList(); iter = iter.Cdr() {
+		declaration := iter.Car()
+		c.compile(declaration.Cdr().Car())
+		c.emit(OpBind, c.constant(declaration.Car()), 0)
+		c.scope = &interpreter.Scope{Symbols: []*Value{declaration.Car()}, Parent: c.scope}
+		count++
+	}
+
+	c.compileBody(args.Cdr())
+	c.scope = outer
+	c.emit(OpUnbind, count, 0)
+	jumpToEnd := c.emit(OpJump, 0, 0)
+
+	c.fallback(form, guard, jumpToEnd)
+}
+
+// Leaves the value of the last form on the stack, () if there are none
+func (c *compiler) compileBody(forms *Value) {
+	if forms.IsEmptyList() {
+		c.emit(OpConst, c.constant(BuildEmptyList()), 0)
+		return
+	}
+
+	for iter := forms; !iter.IsEmptyList(); iter = iter.Cdr() {
+		c.compile(iter.Car())
+		if !iter.Cdr().IsEmptyList() {
+			c.emit(OpPop, 0, 0)
+		}
+	}
+}
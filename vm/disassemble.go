@@ -0,0 +1,63 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	. "nondv.io/glisp/types"
+)
+
+// Human-readable listing of the instructions, one per line:
+//
+//	0000 LOCAL 0 1 ; x
+func (code *Code) Disassemble() string {
+	var sb strings.Builder
+	// symbols bound by OpBind so far, innermost last. Lets are compiled to
+	// straight code so their frames nest the same way in the listing
+	var bound []*Value
+
+	for i, instruction := range code.Instructions {
+		fmt.Fprintf(&sb, "%04d %s", i, instruction.Op)
+
+		switch instruction.Op {
+		case OpConst, OpGlobal, OpCallOut, OpBind:
+			fmt.Fprintf(&sb, " %d ; %s", instruction.A, code.Constants[instruction.A].PrintStr())
+		case OpLocal:
+			fmt.Fprintf(&sb, " %d %d", instruction.A, instruction.B)
+			if comment := code.localName(bound, instruction.A, instruction.B); comment != "" {
+				fmt.Fprintf(&sb, " ; %s", comment)
+			}
+		case OpPrepareCall, OpCheckSpecial:
+			fmt.Fprintf(&sb, " %d %d ; %s", instruction.A, instruction.B, code.Constants[instruction.A].PrintStr())
+		case OpJump, OpJumpIfFalse, OpCall, OpUnbind:
+			fmt.Fprintf(&sb, " %d", instruction.A)
+		}
+
+		switch instruction.Op {
+		case OpBind:
+			bound = append(bound, code.Constants[instruction.A])
+		case OpUnbind:
+			bound = bound[:max(len(bound)-instruction.A, 0)]
+		}
+
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// Let variables in bound, then parameters (other frames belong to callers)
+func (code *Code) localName(bound []*Value, depth int, i int) string {
+	if depth < len(bound) {
+		if i != 0 {
+			return ""
+		}
+		return bound[len(bound)-1-depth].SymbolName()
+	}
+
+	if depth != len(bound) || i >= len(code.Params) {
+		return ""
+	}
+
+	return code.Params[i].SymbolName()
+}
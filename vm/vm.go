@@ -0,0 +1,141 @@
+package vm
+
+import (
+	"errors"
+
+	"nondv.io/glisp/interpreter"
+	. "nondv.io/glisp/types"
+)
+
+// Compiles and runs a form. Gives the same result as interpreter.Eval
+func Eval(b *Bindings, form *Value) (*Value, error) {
+	return Run(b, Compile(form))
+}
+
+// Runs compiled code. Every run (the top-level form and each lambda call)
+// counts towards the runtime's limits like a call in Eval does
+func Run(b *Bindings, code *Code) (*Value, error) {
+	rt := b.Runtime()
+	if rt != nil {
+		defer rt.Leave()
+		if err := rt.Enter(); err != nil {
+			return nil, err
+		}
+	}
+
+	var stack []*Value
+	// bindings before each OpBind, so OpUnbind can restore them
+	var saved []*Bindings
+
+	pop := func() *Value {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return top
+	}
+
+	for pc := 0; pc < len(code.Instructions); pc++ {
+		instruction := code.Instructions[pc]
+
+		switch instruction.Op {
+		case OpConst:
+			stack = append(stack, code.Constants[instruction.A])
+
+		case OpLocal:
+			stack = append(stack, b.Local(instruction.A, instruction.B))
+
+		case OpGlobal:
+			val, found := interpreter.Lookup(b, code.Constants[instruction.A])
+			if !found {
+				return nil, errors.New("Undefined")
+			}
+			stack = append(stack, val)
+
+		case OpJump:
+			pc = instruction.A - 1
+
+		case OpJumpIfFalse:
+			if !pop().IsTruthy() {
+				pc = instruction.A - 1
+			}
+
+		case OpPop:
+			pop()
+
+		case OpPrepareCall:
+			fn := stack[len(stack)-1]
+			if fn.IsNativeFn() && fn.ToNativeFn().EvalArgs {
+				continue
+			}
+			if cachedLambda(fn) != nil {
+				continue
+			}
+
+			pop()
+			result, err := interpreter.Call(b, fn, code.Constants[instruction.A])
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, result)
+			pc = instruction.B - 1
+
+		case OpCall:
+			args := make([]*Value, instruction.A)
+			copy(args, stack[len(stack)-instruction.A:])
+			stack = stack[:len(stack)-instruction.A]
+			fn := pop()
+
+			result, err := call(b, fn, args)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, result)
+
+		case OpCallOut:
+			result, err := interpreter.Eval(b, code.Constants[instruction.A])
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, result)
+
+		case OpBind:
+			saved = append(saved, b)
+			b = b.Assoc(code.Constants[instruction.A], pop())
+
+		case OpUnbind:
+			b = saved[len(saved)-instruction.A]
+			saved = saved[:len(saved)-instruction.A]
+
+		case OpCheckSpecial:
+			sym := code.Constants[instruction.A]
+			if val, _ := b.Lookup(sym); val != interpreter.SpecialForm(sym.SymbolName()) {
+				pc = instruction.B - 1
+			}
+
+		case OpReturn:
+			return pop(), nil
+
+		default:
+			return nil, errors.New("unknown opcode")
+		}
+	}
+
+	return nil, errors.New("no return instruction")
+}
+
+// fn has been checked by OpPrepareCall
+func call(b *Bindings, fn *Value, args []*Value) (*Value, error) {
+	if fn.IsNativeFn() {
+		list := BuildEmptyList()
+		for i := len(args) - 1; i >= 0; i-- {
+			list = BuildCons(args[i], list)
+		}
+		return fn.ToNativeFn().Fn(b, list)
+	}
+
+	lambda := cachedLambda(fn)
+	if len(lambda.Params) != len(args) {
+		return nil, errors.New("too many/not enough arguments")
+	}
+
+	return Run(b.Extend(lambda.Params, args), lambda)
+}
@@ -0,0 +1,119 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"nondv.io/glisp/interpreter"
+	"nondv.io/glisp/reader"
+	. "nondv.io/glisp/types"
+)
+
+func read(t *testing.T, code string) *Value {
+	form, err := reader.Read(code)
+	require.NoError(t, err)
+	return form
+}
+
+func TestCompile(t *testing.T) {
+	expected := `0000 CHECK_SPECIAL 0 12 ; let
+0001 CONST 1 ; 1
+0002 BIND 2 ; a
+0003 LOCAL 0 0 ; a
+0004 BIND 3 ; b
+0005 GLOBAL 4 ; f
+0006 PREPARE_CALL 5 10 ; (a b)
+0007 LOCAL 1 0 ; a
+0008 LOCAL 0 0 ; b
+0009 CALL 2
+0010 UNBIND 2
+0011 JUMP 13
+0012 CALL_OUT 6 ; (let ((a 1) (b a)) (f a b))
+0013 RETURN
+`
+	require.Equal(t, expected, Compile(read(t, "(let ((a 1) (b a)) (f a b))")).Disassemble())
+
+	// forms the compiler doesn't handle itself
+	require.Equal(t, "0000 CONST 0 ; (lambda (x))\n0001 RETURN\n", Compile(read(t, "(lambda (x))")).Disassemble())
+	require.Equal(t, "0000 CALL_OUT 0 ; (f . 1)\n0001 RETURN\n", Compile(read(t, "(f . 1)")).Disassemble())
+	require.NotContains(t, Compile(read(t, "(if 1 2)")).Disassemble(), "CHECK_SPECIAL")
+}
+
+func TestCompileLambda(t *testing.T) {
+	code, err := CompileLambda(read(t, "(lambda (x y) (if x (let ((z x)) z y) 0))"))
+	require.NoError(t, err)
+
+	expected := `0000 CHECK_SPECIAL 0 15 ; if
+0001 LOCAL 0 0 ; x
+0002 JUMP_IF_FALSE 13
+0003 CHECK_SPECIAL 1 11 ; let
+0004 LOCAL 0 0 ; x
+0005 BIND 2 ; z
+0006 LOCAL 0 0 ; z
+0007 POP
+0008 LOCAL 1 1 ; y
+0009 UNBIND 1
+0010 JUMP 12
+0011 CALL_OUT 3 ; (let ((z x)) z y)
+0012 JUMP 16
+0013 CONST 4 ; 0
+0014 JUMP 16
+0015 CALL_OUT 5 ; (if x (let ((z x)) z y) 0)
+0016 RETURN
+`
+	require.Equal(t, expected, code.Disassemble())
+
+	// an empty body returns ()
+	code, err = CompileLambda(read(t, "(lambda ())"))
+	require.NoError(t, err)
+	require.Equal(t, "0000 CONST 0 ; ()\n0001 RETURN\n", code.Disassemble())
+
+	_, err = CompileLambda(read(t, "(lambda (&optional x) x)"))
+	require.NotNil(t, err)
+	_, err = CompileLambda(read(t, "(1 2)"))
+	require.NotNil(t, err)
+}
+
+func TestRun(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+
+	// OpUnbind drops several frames at once
+	code := &Code{
+		Instructions: []Instruction{
+			{OpConst, 0, 0}, {OpBind, 1, 0},
+			{OpConst, 0, 0}, {OpBind, 2, 0},
+			{OpUnbind, 2, 0},
+			{OpGlobal, 1, 0}, {OpReturn, 0, 0},
+		},
+		Constants: []*Value{BuildInteger(1), BuildSymbol("a"), BuildSymbol("b")},
+	}
+	_, err := Run(bindings, code)
+	require.EqualError(t, err, "Undefined")
+
+	// the guard falls back when if is rebound
+	code = Compile(read(t, "(if 1 2 3)"))
+	result, err := Run(bindings, code)
+	require.NoError(t, err)
+	require.Equal(t, "2", result.PrintStr())
+	_, err = Run(bindings.AssocSym("if", BuildInteger(0)), code)
+	require.NotNil(t, err)
+
+	// functions that don't evaluate their arguments get the forms
+	fexpr := read(t, "(lambda ARGS (car ARGS))")
+	code = Compile(read(t, "(f (undefined))"))
+	result, err = Run(bindings.AssocSym("f", fexpr), code)
+	require.NoError(t, err)
+	require.Equal(t, "(undefined)", result.PrintStr())
+
+	_, err = Run(bindings, &Code{Instructions: []Instruction{{Opcode(100), 0, 0}}})
+	require.EqualError(t, err, "unknown opcode")
+	_, err = Run(bindings, &Code{Instructions: []Instruction{{OpConst, 0, 0}}, Constants: []*Value{BuildInteger(1)}})
+	require.EqualError(t, err, "no return instruction")
+}
+
+func TestOpcodeString(t *testing.T) {
+	require.Equal(t, "CONST", OpConst.String())
+	require.Equal(t, "RETURN", OpReturn.String())
+	require.Len(t, opcodeNames, int(OpReturn)+1)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"nondv.io/glisp/interpreter"
+	"nondv.io/glisp/reader"
+	. "nondv.io/glisp/types"
+	"nondv.io/glisp/vm"
+)
+
+func TestVM(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	_, err := interpreter.ReadEval(bindings, `(load "lang/core.lisp")`)
+	require.NoError(t, err)
+
+	cases := append(compilerCases,
+		"((lambda (f x) (f (f x))) (lambda (y) (+ y y)) 3)",
+		"((lambda (x) (if x (let ((y 1)) (+ x y)) 0)) 5)",
+		"((lambda (n) (let ((a 1)) (let ((b 2)) (+ n a b)))) 10)",
+	)
+
+	for _, code := range cases {
+		expected := readEvalPrintNoErr(bindings, code)
+		require.Equal(t, expected, vmRunPrintNoErr(bindings, code), code)
+	}
+}
+
+// The tests written for Eval, evaluated by the VM
+func TestVMSuite(t *testing.T) {
+	evalForm = vm.Eval
+	defer func() { evalForm = interpreter.Eval }()
+
+	for _, test := range []struct {
+		name string
+		run  func(*testing.T)
+	}{
+		{"Lisp", TestLisp}, {"Bool", TestBool}, {"Keyword", TestKeyword},
+		{"HashMap", TestHashMap}, {"Vector", TestVector}, {"NoLet", TestNoLet},
+		{"Base", TestBase}, {"Define", TestDefine}, {"Set", TestSet},
+		{"DestructuringLet", TestDestructuringLet}, {"DestructuringMaps", TestDestructuringMaps},
+		{"DestructuringParams", TestDestructuringParams}, {"DestructuringErrors", TestDestructuringErrors},
+		{"Match", TestMatch}, {"MatchPredicates", TestMatchPredicates},
+		{"MatchRouting", TestMatchRouting}, {"MatchErrors", TestMatchErrors},
+		{"RestParams", TestRestParams}, {"OptionalParams", TestOptionalParams},
+		{"KeyParams", TestKeyParams}, {"InvalidLambdaLists", TestInvalidLambdaLists},
+		{"Apply", TestApply}, {"Funcall", TestFuncall}, {"ComposePartial", TestComposePartial},
+		{"PVector", TestPVector}, {"PMap", TestPMap}, {"PersistentCollections", TestPersistentCollections},
+		{"Atoms", TestAtoms}, {"WithLock", TestWithLock}, {"Dosync", TestDosync},
+		{"Spawn", TestSpawn}, {"Channels", TestChannels}, {"Select", TestSelect},
+	} {
+		t.Run(test.name, test.run)
+	}
+}
+
+// Lambda calls count towards the runtime's limits like in Eval
+func TestVMLimits(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+	readEval(bindings, "(define loop (lambda (n) (loop (+ n 1))))")
+	readEval(bindings, "(define deep (lambda (n) (if (= n 0) 0 (+ 1 (deep (+ n -1))))))")
+	readEval(bindings, "(define spin (lambda (n) (if (= n 0) 0 (spin (+ n -1)))))")
+
+	run := func(rt *Runtime, code string) error {
+		form, err := reader.Read(code)
+		require.NoError(t, err)
+		_, err = vm.Eval(bindings.WithRuntime(rt), form)
+		return err
+	}
+
+	require.Equal(t, &LimitExceededError{Limit: "max depth"}, run(&Runtime{MaxDepth: 100}, "(deep 1000)"))
+	require.Equal(t, &LimitExceededError{Limit: "max steps"}, run(&Runtime{MaxSteps: 1000, MaxDepth: 100000}, "(spin 100000)"))
+	require.NoError(t, run(&Runtime{MaxDepth: 1000}, "(deep 10)"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, run(&Runtime{Context: ctx}, "(loop 0)"), context.Canceled)
+}
+
+func TestVMErrors(t *testing.T) {
+	bindings := interpreter.BuildBaseBindings()
+
+	for _, code := range []string{"undefined", "(if 1 2)", "((lambda (x) x))", "((lambda (x) x) 1 2)", "(1 2)", "(+ 1 . 2)", "(car . 1)", "(car (+ 1 . 2))"} {
+		_, evalErr := interpreter.ReadEval(bindings, code)
+		require.NotNil(t, evalErr, code)
+
+		form, err := reader.Read(code)
+		require.NoError(t, err)
+		_, vmErr := vm.Eval(bindings, form)
+		require.Equal(t, evalErr, vmErr, code)
+	}
+
+	form, _ := reader.Read("(lambda X X)")
+	_, err := vm.CompileLambda(form)
+	require.NotNil(t, err)
+}
+
+func TestDisassemble(t *testing.T) {
+	form, err := reader.Read("(lambda (x) (if x (+ x 1) 0))")
+	require.NoError(t, err)
+
+	code, err := vm.CompileLambda(form)
+	require.NoError(t, err)
+
+	listing := code.Disassemble()
+	require.True(t, strings.HasPrefix(listing, "0000 CHECK_SPECIAL 0 "), listing)
+	require.Contains(t, listing, "0001 LOCAL 0 0 ; x\n")
+	require.Contains(t, listing, "GLOBAL 1 ; +\n")
+	require.True(t, strings.HasSuffix(listing, "RETURN\n"), listing)
+}
+
+func vmRunPrintNoErr(bindings *Bindings, txt string) string {
+	form, err := reader.Read(txt)
+	if err != nil {
+		panic(err)
+	}
+
+	res, err := vm.Eval(bindings, form)
+	if err != nil {
+		println(err.Error())
+		panic("err!")
+	}
+	return res.PrintStr()
+}